@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/devodev/grpc-demo/cmd/client/grpc"
+
+	"github.com/spf13/cobra"
+)
+
+func newCommandCall() *cobra.Command {
+	dialerCfg := grpc.NewDialerConfig()
+	descriptorCfg := grpc.NewDescriptorConfig()
+	config := grpc.NewConfig()
+	cmd := &cobra.Command{
+		Use:   "call <target> <package.Service/Method>",
+		Short: "Call any method of a client registered on the hub.",
+		Long: `Call any method of a client registered on the hub.
+
+The method is looked up in the descriptor files, the .protoset and .proto
+files of the flags and of the descriptor directory, then discovered using
+the server reflection service of the target. Client streaming methods
+send the requests decoded until the end of the request file, and the
+responses are encoded as they are received.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := descriptorCfg.Source()
+			if err != nil {
+				return err
+			}
+			if files != nil && config.PrintSampleRequest {
+				// No need to reach the target.
+				if method, err := grpc.FindMethod(files, args[1]); err == nil {
+					return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
+						return grpc.EncodeSample(out, method.Input())
+					})
+				}
+			}
+
+			dialer, err := grpc.NewDialer(dialerCfg)
+			if err != nil {
+				return err
+			}
+			conn, err := dialer.Dial(grpc.TargetOptions(args[0])...)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			ctx := context.Background()
+			var source grpc.DescriptorSource = grpc.NewReflectionSource(ctx, conn)
+			if files != nil {
+				source = grpc.DescriptorSources{files, source}
+			}
+			method, err := grpc.FindMethod(source, args[1])
+			if err != nil {
+				return err
+			}
+			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
+				if cfg.PrintSampleRequest {
+					return grpc.EncodeSample(out, method.Input())
+				}
+				return grpc.Invoke(ctx, conn, method, in, out)
+			})
+		},
+	}
+	cmd.Flags().SortFlags = false
+	dialerCfg.ProcessEnv()
+	dialerCfg.AddFlags(cmd.Flags())
+	descriptorCfg.AddFlags(cmd.Flags())
+	config.AddFlags(cmd.Flags())
+	return cmd
+}
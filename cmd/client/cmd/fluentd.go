@@ -7,7 +7,6 @@ import (
 	pb "github.com/devodev/grpc-demo/internal/pb/remote"
 
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc/metadata"
 )
 
 func newCommandFluentd() *cobra.Command {
@@ -31,16 +30,11 @@ func newCommandFluentdStart() *cobra.Command {
 		Short: "Start the Fluentd service.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name := args[0]
-
-			md := metadata.New(map[string]string{"name": name})
-			mdCtx := metadata.NewOutgoingContext(context.Background(), md)
-
 			dialer, err := grpc.NewDialer(dialerCfg)
 			if err != nil {
 				return err
 			}
-			conn, err := dialer.Dial()
+			conn, err := dialer.Dial(grpc.TargetOptions(args[0])...)
 			if err != nil {
 				return err
 			}
@@ -60,13 +54,13 @@ func newCommandFluentdStart() *cobra.Command {
 
 			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
 				if cfg.PrintSampleRequest {
-					return out.Encode(&v)
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
 				}
 				err := in.Decode(&v)
 				if err != nil {
 					return err
 				}
-				resp, err := fn(mdCtx, &v)
+				resp, err := fn(context.Background(), &v)
 				if err != nil {
 					return err
 				}
@@ -104,7 +98,7 @@ func newCommandFluentdStop() *cobra.Command {
 			fn := client.Stop
 			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
 				if cfg.PrintSampleRequest {
-					return out.Encode(&v)
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
 				}
 				err := in.Decode(&v)
 				if err != nil {
@@ -148,7 +142,7 @@ func newCommandFluentdRestart() *cobra.Command {
 			fn := client.Restart
 			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
 				if cfg.PrintSampleRequest {
-					return out.Encode(&v)
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
 				}
 				err := in.Decode(&v)
 				if err != nil {
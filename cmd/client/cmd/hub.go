@@ -17,7 +17,9 @@ func newCommandHub() *cobra.Command {
 	}
 	cmd.AddCommand(
 		newCommandHubListClients(),
+		newCommandHubGetClient(),
 		newCommandHubActivityFeed(),
+		newCommandHubCreateEnrollmentToken(),
 	)
 	return cmd
 }
@@ -46,7 +48,7 @@ func newCommandHubListClients() *cobra.Command {
 
 			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
 				if cfg.PrintSampleRequest {
-					return out.Encode(&v)
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
 				}
 				err := in.Decode(&v)
 				if err != nil {
@@ -67,6 +69,54 @@ func newCommandHubListClients() *cobra.Command {
 	return cmd
 }
 
+func newCommandHubGetClient() *cobra.Command {
+	dialerCfg := grpc.NewDialerConfig()
+	config := grpc.NewConfig()
+	cmd := &cobra.Command{
+		Use:   "get-client [name]",
+		Short: "Get connection and traffic details of a client connected to hub.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dialer, err := grpc.NewDialer(dialerCfg)
+			if err != nil {
+				return err
+			}
+			conn, err := dialer.Dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			hubClient := pb.NewHubClient(conn)
+
+			var v pb.HubGetClientRequest
+			fn := hubClient.GetClient
+
+			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
+				if cfg.PrintSampleRequest {
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
+				}
+				err := in.Decode(&v)
+				if err != nil {
+					return err
+				}
+				if len(args) > 0 {
+					v.Name = args[0]
+				}
+				resp, err := fn(context.Background(), &v)
+				if err != nil {
+					return err
+				}
+				return out.Encode(resp)
+			})
+		},
+	}
+	cmd.Flags().SortFlags = false
+	dialerCfg.ProcessEnv()
+	dialerCfg.AddFlags(cmd.Flags())
+	config.AddFlags(cmd.Flags())
+	return cmd
+}
+
 func newCommandHubActivityFeed() *cobra.Command {
 	dialerCfg := grpc.NewDialerConfig()
 	config := grpc.NewConfig()
@@ -91,7 +141,7 @@ func newCommandHubActivityFeed() *cobra.Command {
 
 			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
 				if cfg.PrintSampleRequest {
-					return out.Encode(&v)
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
 				}
 				if err := in.Decode(&v); err != nil {
 					return err
@@ -124,3 +174,51 @@ func newCommandHubActivityFeed() *cobra.Command {
 	config.AddFlags(cmd.Flags())
 	return cmd
 }
+
+func newCommandHubCreateEnrollmentToken() *cobra.Command {
+	dialerCfg := grpc.NewDialerConfig()
+	config := grpc.NewConfig()
+	cmd := &cobra.Command{
+		Use:   "create-enrollment-token [name]",
+		Short: "Create a single-use token allowing an agent to enroll with the hub certificate authority.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dialer, err := grpc.NewDialer(dialerCfg)
+			if err != nil {
+				return err
+			}
+			conn, err := dialer.Dial()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			hubClient := pb.NewHubClient(conn)
+
+			var v pb.HubCreateEnrollmentTokenRequest
+			fn := hubClient.CreateEnrollmentToken
+
+			return config.RoundTrip(func(cfg *grpc.Config, in grpc.Decoder, out grpc.Encoder) error {
+				if cfg.PrintSampleRequest {
+					return grpc.EncodeSample(out, v.ProtoReflect().Descriptor())
+				}
+				err := in.Decode(&v)
+				if err != nil {
+					return err
+				}
+				if len(args) > 0 {
+					v.Name = args[0]
+				}
+				resp, err := fn(context.Background(), &v)
+				if err != nil {
+					return err
+				}
+				return out.Encode(resp)
+			})
+		},
+	}
+	cmd.Flags().SortFlags = false
+	dialerCfg.ProcessEnv()
+	dialerCfg.AddFlags(cmd.Flags())
+	config.AddFlags(cmd.Flags())
+	return cmd
+}
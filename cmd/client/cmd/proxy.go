@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/devodev/grpc-demo/cmd/client/grpc"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/spf13/cobra"
+)
+
+// proxyConfig holds config for the proxy command.
+type proxyConfig struct {
+	ListenAddr string `envconfig:"PROXY_LISTEN_ADDR" default:"localhost:7000"`
+	Target     string `envconfig:"PROXY_TARGET"`
+}
+
+func newCommandProxy() *cobra.Command {
+	dialerCfg := grpc.NewDialerConfig()
+	config := &proxyConfig{}
+	envconfig.Process("", config)
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Serve a local gRPC server forwarding every call to a client through the hub.",
+		Long: `Serve a local gRPC server forwarding every call to a client through the hub.
+
+The calls are forwarded as is, whatever their service, along with their
+metadata. The target name and the auth token are added to their metadata,
+so that any gRPC tool can reach the target as if it were local.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if config.Target == "" {
+				return fmt.Errorf("missing target")
+			}
+			dialer, err := grpc.NewDialer(dialerCfg)
+			if err != nil {
+				return err
+			}
+			conn, err := dialer.Dial(grpc.ProxyDialOptions(config.Target)...)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			l, err := net.Listen("tcp", config.ListenAddr)
+			if err != nil {
+				return err
+			}
+			server := grpc.NewProxyServer(conn)
+			done := make(chan error, 1)
+			go func() { done <- server.Serve(l) }()
+			log.Printf("forwarding the calls received on %v to %v", l.Addr(), config.Target)
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			select {
+			case <-interrupt:
+				log.Println("graceful shutdown..")
+				server.GracefulStop()
+				return nil
+			case err := <-done:
+				return err
+			}
+		},
+	}
+	cmd.Flags().SortFlags = false
+	cmd.Flags().StringVarP(&config.ListenAddr, "listen", "l", config.ListenAddr, "local gRPC server listening address")
+	cmd.Flags().StringVarP(&config.Target, "target", "t", config.Target, "name of the client the calls are forwarded to")
+	dialerCfg.ProcessEnv()
+	dialerCfg.AddFlags(cmd.Flags())
+	return cmd
+}
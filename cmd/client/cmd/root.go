@@ -32,8 +32,10 @@ func newCommandRoot() *cobra.Command {
 		Version: "0.1.0",
 	}
 	cmd.AddCommand(
+		newCommandCall(),
 		newCommandFluentd(),
 		newCommandHub(),
+		newCommandProxy(),
 	)
 	return cmd
 }
@@ -6,15 +6,23 @@ import (
 	"encoding/xml"
 	"io"
 
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"gopkg.in/yaml.v2"
 )
 
 // DefaultEncoders contains the default list of encoders per MIME type.
+// Proto messages are encoded using their JSON mapping, see ProtoEncoder,
+// except by the proto (wire format, each message preceded by its size as
+// a varint) and textproto (messages separated by --- lines) encoders,
+// which only encode proto messages.
 var DefaultEncoders = EncoderGroup{
-	"xml":        EncoderMakerFunc(func(w io.Writer) Encoder { return &xmlEncoder{w} }),
-	"json":       EncoderMakerFunc(func(w io.Writer) Encoder { return &jsonEncoder{w, false} }),
-	"prettyjson": EncoderMakerFunc(func(w io.Writer) Encoder { return &jsonEncoder{w, true} }),
-	"yaml":       EncoderMakerFunc(func(w io.Writer) Encoder { return &yamlEncoder{w} }),
+	"xml":        EncoderMakerFunc(func(w io.Writer) Encoder { return &xmlEncoder{w: w} }),
+	"json":       EncoderMakerFunc(func(w io.Writer) Encoder { return &jsonEncoder{w: w} }),
+	"prettyjson": EncoderMakerFunc(func(w io.Writer) Encoder { return &jsonEncoder{w: w, pretty: true} }),
+	"yaml":       EncoderMakerFunc(func(w io.Writer) Encoder { return &yamlEncoder{w: w} }),
+	"proto":      EncoderMakerFunc(func(w io.Writer) Encoder { return &protoEncoder{w} }),
+	"textproto":  EncoderMakerFunc(func(w io.Writer) Encoder { return &textEncoder{w: w} }),
 }
 
 type (
@@ -23,6 +31,15 @@ type (
 		Encode(v interface{}) error
 	}
 
+	// A ProtoEncoder is an Encoder encoding proto messages using their
+	// JSON mapping, see protojson, whose options can be changed.
+	// The YAML and XML encodings are derived from it.
+	ProtoEncoder interface {
+		Encoder
+		MarshalOptions() protojson.MarshalOptions
+		SetMarshalOptions(opts protojson.MarshalOptions)
+	}
+
 	// An EncoderGroup maps MIME types to EncoderMakers.
 	EncoderGroup map[string]EncoderMaker
 
@@ -41,7 +58,35 @@ func (f EncoderMakerFunc) NewEncoder(w io.Writer) Encoder {
 	return f(w)
 }
 
+// marshaler holds the options of the ProtoEncoders.
+type marshaler struct {
+	opts protojson.MarshalOptions
+}
+
+func (m *marshaler) MarshalOptions() protojson.MarshalOptions {
+	return m.opts
+}
+
+func (m *marshaler) SetMarshalOptions(opts protojson.MarshalOptions) {
+	m.opts = opts
+}
+
+// marshal returns the compact JSON mapping of msg. The whitespace
+// of protojson is unstable on purpose, so it is compacted.
+func (m *marshaler) marshal(msg proto.Message) ([]byte, error) {
+	b, err := m.opts.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Compact(&out, b); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
 type xmlEncoder struct {
+	marshaler
 	w io.Writer
 }
 
@@ -50,40 +95,71 @@ func (xe *xmlEncoder) Encode(v interface{}) error {
 	defer xe.w.Write([]byte("\n"))
 	e := xml.NewEncoder(xe.w)
 	e.Indent("", "\t")
-	return e.Encode(v)
+	m, ok := protoMessage(v)
+	if !ok {
+		return e.Encode(v)
+	}
+	b, err := xe.marshal(m)
+	if err != nil {
+		return err
+	}
+	tree, err := jsonTree(b)
+	if err != nil {
+		return err
+	}
+	desc := m.ProtoReflect().Descriptor()
+	if err := encodeXMLTree(e, xmlElement(string(desc.Name())), desc, tree); err != nil {
+		return err
+	}
+	return e.Flush()
 }
 
 type jsonEncoder struct {
+	marshaler
 	w      io.Writer
 	pretty bool
 }
 
 func (je *jsonEncoder) Encode(v interface{}) error {
+	var b []byte
+	var err error
+	if m, ok := protoMessage(v); ok {
+		b, err = je.marshal(m)
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
 	if je.pretty {
-		b, err := json.Marshal(v)
-		if err != nil {
-			return err
-		}
-		var out bytes.Buffer
 		err = json.Indent(&out, b, "", "\t")
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(je.w, &out)
-		if err != nil {
-			return err
-		}
-		_, err = je.w.Write([]byte("\n"))
-		return err
+	} else {
+		out.Write(b)
 	}
-	return json.NewEncoder(je.w).Encode(v)
+	out.WriteByte('\n')
+	_, err = io.Copy(je.w, &out)
+	return err
 }
 
 type yamlEncoder struct {
+	marshaler
 	w io.Writer
 }
 
 func (ye *yamlEncoder) Encode(v interface{}) error {
+	if m, ok := protoMessage(v); ok {
+		b, err := ye.marshal(m)
+		if err != nil {
+			return err
+		}
+		if v, err = jsonTree(b); err != nil {
+			return err
+		}
+	}
 	b, err := yaml.Marshal(v)
 	if err != nil {
 		return err
@@ -0,0 +1,221 @@
+package grpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorConfig holds the descriptor files used to call methods
+// dynamically, without relying on the server reflection service.
+type DescriptorConfig struct {
+	ProtosetFiles []string `envconfig:"PROTOSET_FILES"`
+	ProtoFiles    []string `envconfig:"PROTO_FILES"`
+	ImportPaths   []string `envconfig:"PROTO_IMPORT_PATHS"`
+	Dir           string   `envconfig:"DESCRIPTOR_DIR"`
+}
+
+// NewDescriptorConfig returns DescriptorConfig after being processed
+// using envconfig to set default values and environment variables.
+// The descriptor directory defaults to grpc-demo/descriptors in the
+// user configuration directory.
+func NewDescriptorConfig() *DescriptorConfig {
+	c := &DescriptorConfig{Dir: defaultDescriptorDir()}
+	envconfig.Process("", c)
+	return c
+}
+
+func defaultDescriptorDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "grpc-demo", "descriptors")
+}
+
+// AddFlags adds flags to the provided flagset.
+func (c *DescriptorConfig) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&c.ProtosetFiles, "protoset", c.ProtosetFiles, "compiled descriptor set file, such as generated by protoc --descriptor_set_out --include_imports (repeatable)")
+	fs.StringSliceVar(&c.ProtoFiles, "proto", c.ProtoFiles, "proto source file, relative to an import path (repeatable)")
+	fs.StringSliceVarP(&c.ImportPaths, "import-path", "I", c.ImportPaths, "import path of the proto source files (repeatable); defaults to the current directory")
+	fs.StringVar(&c.Dir, "descriptor-dir", c.Dir, "directory whose .protoset and .proto files are loaded, the latter relative to it")
+}
+
+// Source returns the DescriptorSource of the descriptor files,
+// or nil if there are none.
+func (c *DescriptorConfig) Source() (DescriptorSource, error) {
+	protosets := c.ProtosetFiles
+	var dirProtos []string
+	if c.Dir != "" {
+		err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch filepath.Ext(path) {
+			case ".protoset":
+				protosets = append(protosets, path)
+			case ".proto":
+				rel, err := filepath.Rel(c.Dir, path)
+				if err != nil {
+					return err
+				}
+				dirProtos = append(dirProtos, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		// The default directory is optional.
+		if err != nil && !(os.IsNotExist(err) && c.Dir == defaultDescriptorDir()) {
+			return nil, fmt.Errorf("descriptor dir: %v", err)
+		}
+	}
+
+	set := newFileSet()
+	for _, path := range protosets {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("protoset: %v", err)
+		}
+		var fds descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &fds); err != nil {
+			return nil, fmt.Errorf("protoset %v: %v", path, err)
+		}
+		for _, fd := range fds.GetFile() {
+			set.add(fd)
+		}
+	}
+	if len(c.ProtoFiles) > 0 {
+		if err := set.parse(protoparse.Parser{ImportPaths: c.ImportPaths}, c.ProtoFiles); err != nil {
+			return nil, err
+		}
+	}
+	if len(dirProtos) > 0 {
+		parser := protoparse.Parser{ImportPaths: append([]string{c.Dir}, c.ImportPaths...)}
+		if err := set.parse(parser, dirProtos); err != nil {
+			return nil, err
+		}
+	}
+	if len(set.order) == 0 {
+		return nil, nil
+	}
+	files, err := set.registry()
+	if err != nil {
+		return nil, err
+	}
+	return &fileSource{files}, nil
+}
+
+// fileSet collects the file descriptors of the descriptor files.
+type fileSet struct {
+	files map[string]*descriptorpb.FileDescriptorProto
+	order []string
+}
+
+func newFileSet() *fileSet {
+	return &fileSet{files: make(map[string]*descriptorpb.FileDescriptorProto)}
+}
+
+func (s *fileSet) add(fd *descriptorpb.FileDescriptorProto) {
+	if _, ok := s.files[fd.GetName()]; ok {
+		return
+	}
+	s.files[fd.GetName()] = fd
+	s.order = append(s.order, fd.GetName())
+}
+
+// parse adds the proto source files and their imports.
+func (s *fileSet) parse(parser protoparse.Parser, filenames []string) error {
+	fds, err := parser.ParseFiles(filenames...)
+	if err != nil {
+		return fmt.Errorf("proto: %v", err)
+	}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		s.add(fd.AsFileDescriptorProto())
+	}
+	for _, fd := range fds {
+		add(fd)
+	}
+	return nil
+}
+
+// registry returns the registry of the files, in which the imports
+// missing from the descriptor files, such as the well-known types of
+// a descriptor set compiled without them, are taken from the ones
+// linked in the binary.
+func (s *fileSet) registry() (*protoregistry.Files, error) {
+	files := &protoregistry.Files{}
+	var register func(name, importedBy string) error
+	register = func(name, importedBy string) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		fd, ok := s.files[name]
+		if !ok {
+			f, err := protoregistry.GlobalFiles.FindFileByPath(name)
+			if err != nil {
+				return fmt.Errorf("file %v imported by %v is missing from the descriptor files", name, importedBy)
+			}
+			return files.RegisterFile(f)
+		}
+		for _, dep := range fd.GetDependency() {
+			if err := register(dep, name); err != nil {
+				return err
+			}
+		}
+		f, err := protodesc.NewFile(fd, files)
+		if err != nil {
+			return fmt.Errorf("file %v: %v", name, err)
+		}
+		return files.RegisterFile(f)
+	}
+	for _, name := range s.order {
+		if err := register(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// fileSource resolves the descriptors from the descriptor files.
+type fileSource struct {
+	files *protoregistry.Files
+}
+
+func (s *fileSource) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := s.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%v not found in the descriptor files", name)
+	}
+	return d, nil
+}
+
+// DescriptorSources resolves the descriptors using each source in turn.
+type DescriptorSources []DescriptorSource
+
+// FindDescriptorByName implements the DescriptorSource interface.
+func (sources DescriptorSources) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	var errs []string
+	for _, s := range sources {
+		d, err := s.FindDescriptorByName(name)
+		if err == nil {
+			return d, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("%v", strings.Join(errs, "; "))
+}
@@ -1,19 +1,26 @@
 package grpc
 
 import (
+	"bufio"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
-	"io/ioutil"
 
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
 	"gopkg.in/yaml.v2"
 )
 
 // DefaultDecoders contains the default list of decoders per MIME type.
+// Proto messages are decoded using their JSON mapping, from which
+// the YAML one is derived, or using the proto formats, see DefaultEncoders.
 var DefaultDecoders = DecoderGroup{
-	"xml":  DecoderMakerFunc(func(r io.Reader) Decoder { return xml.NewDecoder(r) }),
-	"json": DecoderMakerFunc(func(r io.Reader) Decoder { return json.NewDecoder(r) }),
-	"yaml": DecoderMakerFunc(func(r io.Reader) Decoder { return &yamlDecoder{r} }),
+	"xml":       DecoderMakerFunc(func(r io.Reader) Decoder { return &xmlDecoder{xml.NewDecoder(r)} }),
+	"json":      DecoderMakerFunc(func(r io.Reader) Decoder { return &jsonDecoder{json.NewDecoder(r)} }),
+	"yaml":      DecoderMakerFunc(func(r io.Reader) Decoder { return &yamlDecoder{yaml.NewDecoder(r)} }),
+	"proto":     DecoderMakerFunc(func(r io.Reader) Decoder { return &protoDecoder{bufio.NewReader(r)} }),
+	"textproto": DecoderMakerFunc(func(r io.Reader) Decoder { return &textDecoder{r: bufio.NewReader(r)} }),
 }
 
 type (
@@ -40,14 +47,55 @@ func (f DecoderMakerFunc) NewDecoder(r io.Reader) Decoder {
 	return f(r)
 }
 
+// xmlDecoder decodes the elements into the Go fields of generated
+// messages, by name. Dynamic messages have no XML mapping.
+type xmlDecoder struct {
+	d *xml.Decoder
+}
+
+func (xd *xmlDecoder) Decode(v interface{}) error {
+	if _, ok := v.(*dynamicpb.Message); ok {
+		return fmt.Errorf("xml is not supported for messages resolved at runtime")
+	}
+	return xd.d.Decode(v)
+}
+
+type jsonDecoder struct {
+	d *json.Decoder
+}
+
+func (jd *jsonDecoder) Decode(v interface{}) error {
+	m, ok := protoMessage(v)
+	if !ok {
+		return jd.d.Decode(v)
+	}
+	var raw json.RawMessage
+	if err := jd.d.Decode(&raw); err != nil {
+		return err
+	}
+	return protojson.Unmarshal(raw, m)
+}
+
 type yamlDecoder struct {
-	r io.Reader
+	d *yaml.Decoder
 }
 
 func (yd *yamlDecoder) Decode(v interface{}) error {
-	b, err := ioutil.ReadAll(yd.r)
+	m, ok := protoMessage(v)
+	if !ok {
+		return yd.d.Decode(v)
+	}
+	var tree interface{}
+	if err := yd.d.Decode(&tree); err != nil {
+		return err
+	}
+	if tree == nil {
+		// An empty document is an empty message.
+		tree = map[string]interface{}{}
+	}
+	b, err := json.Marshal(jsonValue(tree))
 	if err != nil {
 		return err
 	}
-	return yaml.Unmarshal(b, v)
+	return protojson.Unmarshal(b, m)
 }
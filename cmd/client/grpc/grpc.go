@@ -2,25 +2,29 @@ package grpc
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/hashicorp/yamux"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/pflag"
-	"golang.org/x/oauth2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/oauth"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/devodev/grpc-demo/internal/certs"
+	"github.com/devodev/grpc-demo/internal/hubclient"
 )
 
 // DialerConfig .
 type DialerConfig struct {
 	ServerAddr         string        `envconfig:"SERVER_ADDR" default:"localhost:9090"`
+	HubWSAddr          string        `envconfig:"HUB_WS_ADDR"`
 	Timeout            time.Duration `envconfig:"TIMEOUT" default:"10s"`
 	TLS                bool          `envconfig:"TLS"`
 	ServerName         string        `envconfig:"TLS_SERVER_NAME"`
@@ -30,6 +34,7 @@ type DialerConfig struct {
 	KeyFile            string        `envconfig:"TLS_KEY_FILE"`
 	AuthToken          string        `envconfig:"AUTH_TOKEN"`
 	AuthTokenType      string        `envconfig:"AUTH_TOKEN_TYPE" default:"Bearer"`
+	InsecureAuth       bool          `envconfig:"INSECURE_AUTH"`
 	JWTKey             string        `envconfig:"JWT_KEY"`
 	JWTKeyFile         string        `envconfig:"JWT_KEY_FILE"`
 }
@@ -42,6 +47,9 @@ func NewDialerConfig() *DialerConfig {
 // Dialer .
 type Dialer struct {
 	*DialerConfig
+
+	tunnel     *yamux.Session
+	tunnelHost string
 }
 
 // NewDialer creates a Dialer and applies DialerOptions.
@@ -49,58 +57,54 @@ func NewDialer(cfg *DialerConfig) (*Dialer, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("DialerConfig must be non-nil")
 	}
-	d := &Dialer{cfg}
+	d := &Dialer{DialerConfig: cfg}
 	return d, nil
 }
 
-// Dial computes the dialOptions and then call grpc.Dial.
+// Dial computes the dialOptions, appends extra and then call grpc.Dial.
 // It returns a grpc.ClientConn.
-func (d *Dialer) Dial() (*grpc.ClientConn, error) {
+func (d *Dialer) Dial(extra ...grpc.DialOption) (*grpc.ClientConn, error) {
 	opts, err := d.options()
 	if err != nil {
 		return nil, err
 	}
-	conn, err := grpc.Dial(d.ServerAddr, opts...)
+	opts = append(opts, extra...)
+	target := d.ServerAddr
+	if d.tunnel != nil {
+		target = d.tunnelHost
+	}
+	conn, err := grpc.Dial(target, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
 
+// TargetOptions returns the dial options routing the calls
+// to the client registered on the hub as name.
+func TargetOptions(name string) []grpc.DialOption {
+	md := hubclient.Target(name)
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(hubclient.UnaryClientInterceptor(md)),
+		grpc.WithChainStreamInterceptor(hubclient.StreamClientInterceptor(md)),
+	}
+}
+
 func (d *Dialer) options() ([]grpc.DialOption, error) {
 	opts := []grpc.DialOption{
 		grpc.WithBlock(),
 		grpc.WithTimeout(d.Timeout),
 	}
-	if d.TLS {
-		tlsConfig := &tls.Config{}
-		if d.InsecureSkipVerify {
-			tlsConfig.InsecureSkipVerify = true
-		}
-		if d.CACertFile != "" {
-			cacert, err := ioutil.ReadFile(d.CACertFile)
-			if err != nil {
-				return nil, fmt.Errorf("ca cert: %v", err)
-			}
-			certpool := x509.NewCertPool()
-			certpool.AppendCertsFromPEM(cacert)
-			tlsConfig.RootCAs = certpool
-		}
-		if d.CertFile != "" {
-			if d.KeyFile == "" {
-				return nil, fmt.Errorf("missing key file")
-			}
-			pair, err := tls.LoadX509KeyPair(d.CertFile, d.KeyFile)
-			if err != nil {
-				return nil, fmt.Errorf("cert/key: %v", err)
-			}
-			tlsConfig.Certificates = []tls.Certificate{pair}
+	if d.HubWSAddr != "" {
+		tunnelOpts, err := d.tunnelOptions()
+		if err != nil {
+			return nil, err
 		}
-		if d.ServerName != "" {
-			tlsConfig.ServerName = d.ServerName
-		} else {
-			addr, _, _ := net.SplitHostPort(d.ServerAddr)
-			tlsConfig.ServerName = addr
+		opts = append(opts, tunnelOpts...)
+	} else if d.TLS {
+		tlsConfig, err := d.tlsConfig(d.ServerAddr)
+		if err != nil {
+			return nil, err
 		}
 		cred := credentials.NewTLS(tlsConfig)
 		opts = append(opts, grpc.WithTransportCredentials(cred))
@@ -108,10 +112,7 @@ func (d *Dialer) options() ([]grpc.DialOption, error) {
 		opts = append(opts, grpc.WithInsecure())
 	}
 	if d.AuthToken != "" {
-		cred := oauth.NewOauthAccess(&oauth2.Token{
-			AccessToken: d.AuthToken,
-			TokenType:   d.AuthTokenType,
-		})
+		cred := hubclient.TokenCredentials(d.AuthTokenType, d.AuthToken, d.InsecureAuth)
 		opts = append(opts, grpc.WithPerRPCCredentials(cred))
 	}
 	if d.JWTKey != "" {
@@ -131,9 +132,37 @@ func (d *Dialer) options() ([]grpc.DialOption, error) {
 	return opts, nil
 }
 
+// tlsConfig returns the client TLS configuration used to reach addr.
+func (d *Dialer) tlsConfig(addr string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if d.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if d.ServerName != "" {
+		tlsConfig.ServerName = d.ServerName
+	} else {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		tlsConfig.ServerName = host
+	}
+	if d.CACertFile == "" && d.CertFile == "" && d.KeyFile == "" {
+		return tlsConfig, nil
+	}
+	// The files are watched so that rotated certificates are used
+	// by new connections, such as the ones of a long-running tunnel.
+	reloader, err := certs.NewReloader(d.CertFile, d.KeyFile, d.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	return reloader.ClientConfig(tlsConfig, tlsConfig.ServerName), nil
+}
+
 // AddFlags adds flags to the provided flagset.
 func (d *DialerConfig) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVarP(&d.ServerAddr, "server-addr", "s", d.ServerAddr, "server address in form of host:port")
+	fs.StringVar(&d.HubWSAddr, "hub-ws-uri", d.HubWSAddr, "hub caller websocket uri (ex.: wss://localhost:8080/ws/caller); tunnels calls instead of dialing server-addr")
 	fs.DurationVar(&d.Timeout, "timeout", d.Timeout, "client connection timeout")
 	fs.BoolVar(&d.TLS, "tls", d.TLS, "enable tls")
 	fs.StringVar(&d.ServerName, "tls-server-name", d.ServerName, "tls server name override")
@@ -143,6 +172,7 @@ func (d *DialerConfig) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&d.KeyFile, "tls-key-file", d.KeyFile, "client key file")
 	fs.StringVar(&d.AuthToken, "auth-token", d.AuthToken, "authorization token")
 	fs.StringVar(&d.AuthTokenType, "auth-token-type", d.AuthTokenType, "authorization token type")
+	fs.BoolVar(&d.InsecureAuth, "insecure-auth", d.InsecureAuth, "INSECURE: send the authorization token over plaintext connections")
 	fs.StringVar(&d.JWTKey, "jwt-key", d.JWTKey, "jwt key")
 	fs.StringVar(&d.JWTKeyFile, "jwt-key-file", d.JWTKeyFile, "jwt key file")
 }
@@ -158,6 +188,8 @@ type Config struct {
 	RequestFile        string `envconfig:"REQUEST_FILE"`
 	PrintSampleRequest bool   `envconfig:"PRINT_SAMPLE_REQUEST"`
 	ResponseFormat     string `envconfig:"RESPONSE_FORMAT" default:"json"`
+	ProtoNames         bool   `envconfig:"PROTO_NAMES"`
+	EmitDefaults       bool   `envconfig:"EMIT_DEFAULTS"`
 }
 
 // NewConfig returns Config after being processed
@@ -170,15 +202,19 @@ func NewConfig() *Config {
 
 // AddFlags adds flags to the provided flagset.
 func (c *Config) AddFlags(fs *pflag.FlagSet) {
-	fs.StringVarP(&c.RequestFile, "request-file", "f", c.RequestFile, "client request file (must be json, yaml, or xml); use \"-\" for stdin + json")
+	fs.StringVarP(&c.RequestFile, "request-file", "f", c.RequestFile, "client request file (must be json, yaml, xml, proto, or textproto); use \"-\" for stdin + json")
 	fs.BoolVarP(&c.PrintSampleRequest, "print-sample-request", "p", c.PrintSampleRequest, "print sample request file and exit")
-	fs.StringVarP(&c.ResponseFormat, "response-format", "o", c.ResponseFormat, "response format (json, prettyjson, yaml, or xml)")
+	fs.StringVarP(&c.ResponseFormat, "response-format", "o", c.ResponseFormat, "response format (json, prettyjson, yaml, xml, proto, or textproto)")
+	fs.BoolVar(&c.ProtoNames, "proto-names", c.ProtoNames, "use the field names of the proto files in responses instead of their lowerCamelCase JSON names")
+	fs.BoolVar(&c.EmitDefaults, "emit-defaults", c.EmitDefaults, "list the response fields set to their default value")
 }
 
 // RoundTripFunc .
 type RoundTripFunc func(cfg *Config, in Decoder, out Encoder) error
 
-// RoundTrip .
+// RoundTrip calls fn with the decoder of the request and the encoder
+// of the response. When fn fails with a gRPC error, its status and
+// details are encoded in place of the response, see Status.
 func (c *Config) RoundTrip(fn RoundTripFunc) error {
 	// select encoder
 	em := DefaultEncoders["json"]
@@ -190,6 +226,12 @@ func (c *Config) RoundTrip(fn RoundTripFunc) error {
 		}
 	}
 	e := em.NewEncoder(os.Stdout)
+	if pe, ok := e.(ProtoEncoder); ok {
+		pe.SetMarshalOptions(protojson.MarshalOptions{
+			UseProtoNames:   c.ProtoNames,
+			EmitUnpopulated: c.EmitDefaults,
+		})
+	}
 	// select decoder
 	d := DefaultDecoders["json"].NewDecoder(os.Stdin)
 	if c.RequestFile != "" && c.RequestFile != "-" {
@@ -209,5 +251,9 @@ func (c *Config) RoundTrip(fn RoundTripFunc) error {
 		}
 		d = dm.NewDecoder(f)
 	}
-	return fn(c, d, e)
+	err := fn(c, d, e)
+	if s, ok := status.FromError(err); ok && err != nil {
+		return e.Encode(NewStatus(s))
+	}
+	return err
 }
@@ -0,0 +1,105 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Invoke calls method through conn, decoding its requests from in and
+// encoding its responses to out as they are received. Client streaming
+// methods send requests until in returns io.EOF, while the responses
+// are received.
+func Invoke(ctx context.Context, conn *grpc.ClientConn, method protoreflect.MethodDescriptor, in Decoder, out Encoder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	desc := &grpc.StreamDesc{
+		StreamName:    string(method.Name()),
+		ServerStreams: method.IsStreamingServer(),
+		ClientStreams: method.IsStreamingClient(),
+	}
+	fullMethod := fmt.Sprintf("/%v/%v", method.Parent().FullName(), method.Name())
+	stream, err := conn.NewStream(ctx, desc, fullMethod)
+	if err != nil {
+		return err
+	}
+
+	send := func() error {
+		for {
+			req := dynamicpb.NewMessage(method.Input())
+			if err := in.Decode(req); err != nil {
+				if err == io.EOF && desc.ClientStreams {
+					return stream.CloseSend()
+				}
+				return fmt.Errorf("decoding request: %v", err)
+			}
+			if err := stream.SendMsg(protov1.MessageV1(req)); err != nil {
+				if err == io.EOF {
+					// The call ended, its status is returned by RecvMsg.
+					return nil
+				}
+				return err
+			}
+			if !desc.ClientStreams {
+				return stream.CloseSend()
+			}
+		}
+	}
+	sent := make(chan error, 1)
+	if desc.ClientStreams {
+		go func() {
+			err := send()
+			sent <- err
+			if err != nil {
+				cancel()
+			}
+		}()
+	} else if err := send(); err != nil {
+		return err
+	}
+
+	for {
+		resp := dynamicpb.NewMessage(method.Output())
+		err := stream.RecvMsg(protov1.MessageV1(resp))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Report the request that could not be sent
+			// rather than the cancellation of the call.
+			select {
+			case sendErr := <-sent:
+				if sendErr != nil {
+					return sendErr
+				}
+			default:
+			}
+			return err
+		}
+		if err := out.Encode(resp); err != nil {
+			return err
+		}
+		if !desc.ServerStreams {
+			return nil
+		}
+	}
+}
+
+// EncodeSample encodes a sample message of type desc to out, see newSample.
+// The fields of the sample are listed with their default value.
+func EncodeSample(out Encoder, desc protoreflect.MessageDescriptor) error {
+	if pe, ok := out.(ProtoEncoder); ok {
+		opts := pe.MarshalOptions()
+		defer pe.SetMarshalOptions(opts)
+		sampleOpts := opts
+		sampleOpts.EmitUnpopulated = true
+		pe.SetMarshalOptions(sampleOpts)
+	}
+	return out.Encode(newSample(desc, make(map[protoreflect.FullName]bool)))
+}
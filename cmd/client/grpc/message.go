@@ -0,0 +1,193 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v2"
+)
+
+// protoMessage returns v as a proto message, if it is one
+// of either the current or the legacy API.
+func protoMessage(v interface{}) (proto.Message, bool) {
+	switch m := v.(type) {
+	case proto.Message:
+		return m, true
+	case protov1.Message:
+		return protov1.MessageV2(m), true
+	}
+	return nil, false
+}
+
+// jsonTree decodes the JSON value b into a tree of yaml.MapSlice,
+// []interface{} and scalar values, keeping the order of the fields.
+func jsonTree(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return decodeJSONTree(d)
+}
+
+func decodeJSONTree(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '{' {
+			m := yaml.MapSlice{}
+			for d.More() {
+				k, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONTree(d)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, yaml.MapItem{Key: k, Value: v})
+			}
+			_, err := d.Token()
+			return m, err
+		}
+		l := []interface{}{}
+		for d.More() {
+			v, err := decodeJSONTree(d)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		_, err := d.Token()
+		return l, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	}
+	return t, nil
+}
+
+// jsonValue converts the maps decoded from YAML, whose keys are
+// interface{} values, to maps with string keys.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	}
+	return v
+}
+
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// encodeXMLTree encodes the tree returned by jsonTree for a message of type
+// desc as the element start. Fields are encoded as child elements and lists
+// as repeated elements, while the entries of maps and Structs are encoded
+// as entry elements with a key attribute, like Metadata. desc is nil for
+// the values of unknown type, such as the fields of an Any.
+func encodeXMLTree(e *xml.Encoder, start xml.StartElement, desc protoreflect.MessageDescriptor, v interface{}) error {
+	switch v := v.(type) {
+	case []interface{}:
+		if desc != nil && desc.FullName() == "google.protobuf.ListValue" {
+			desc = desc.Fields().ByName("values").Message()
+		}
+		for _, elem := range v {
+			if err := encodeXMLTree(e, start, desc, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.MapSlice:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			key := fmt.Sprint(item.Key)
+			var err error
+			switch fd := xmlField(desc, key); {
+			case desc != nil && desc.FullName() == "google.protobuf.Struct":
+				err = encodeXMLTree(e, xmlEntry(key), desc.Fields().ByName("fields").MapValue().Message(), item.Value)
+			case desc != nil && desc.FullName() == "google.protobuf.Value":
+				err = encodeXMLTree(e, xmlEntry(key), desc, item.Value)
+			case fd != nil && fd.IsMap():
+				err = encodeXMLMap(e, xmlElement(key), fd.MapValue().Message(), item.Value)
+			case fd != nil:
+				err = encodeXMLTree(e, xmlElement(key), fd.Message(), item.Value)
+			default:
+				err = encodeXMLTree(e, xmlElement(key), nil, item.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case nil:
+		return e.EncodeElement("", start)
+	}
+	return e.EncodeElement(v, start)
+}
+
+// encodeXMLMap encodes the map entries of v, whose values are messages
+// of type desc if not nil, as the element start.
+func encodeXMLMap(e *xml.Encoder, start xml.StartElement, desc protoreflect.MessageDescriptor, v interface{}) error {
+	entries, ok := v.(yaml.MapSlice)
+	if !ok {
+		return encodeXMLTree(e, start, desc, v)
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, item := range entries {
+		if err := encodeXMLTree(e, xmlEntry(fmt.Sprint(item.Key)), desc, item.Value); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlField returns the field of desc named key in the JSON mapping,
+// using either its JSON or its original name.
+func xmlField(desc protoreflect.MessageDescriptor, key string) protoreflect.FieldDescriptor {
+	if desc == nil {
+		return nil
+	}
+	if fd := desc.Fields().ByJSONName(key); fd != nil {
+		return fd
+	}
+	return desc.Fields().ByName(protoreflect.Name(key))
+}
+
+// xmlElement returns the element named name, or an entry element
+// if name is not a valid element name.
+func xmlElement(name string) xml.StartElement {
+	if !xmlName.MatchString(name) {
+		return xmlEntry(name)
+	}
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+func xmlEntry(key string) xml.StartElement {
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// textSeparator is the line separating the messages of a textproto stream,
+// which cannot be part of a message since strings hold no newline.
+const textSeparator = "---"
+
+// maxMessageSize bounds the size of a decoded message, as the gRPC
+// servers do by default, so that a corrupt or hostile stream cannot
+// make the decoders allocate without limit.
+const maxMessageSize = 4 << 20
+
+// wireMessage returns v as a proto message to be encoded in the proto
+// formats. The rendering of an error status is encoded as the
+// google.rpc.Status message it was created from.
+func wireMessage(v interface{}) (proto.Message, error) {
+	if s, ok := v.(*Status); ok && s.status != nil {
+		v = s.status.Proto()
+	}
+	m, ok := protoMessage(v)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto message", v)
+	}
+	return m, nil
+}
+
+// protoEncoder encodes messages in the wire format,
+// each preceded by its size as a varint.
+type protoEncoder struct {
+	w io.Writer
+}
+
+func (pe *protoEncoder) Encode(v interface{}) error {
+	m, err := wireMessage(v)
+	if err != nil {
+		return err
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	size := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(size, uint64(len(b)))
+	_, err = pe.w.Write(append(size[:n], b...))
+	return err
+}
+
+// protoDecoder decodes the messages encoded by protoEncoder.
+type protoDecoder struct {
+	r *bufio.Reader
+}
+
+func (pd *protoDecoder) Decode(v interface{}) error {
+	m, err := wireMessage(v)
+	if err != nil {
+		return err
+	}
+	size, err := binary.ReadUvarint(pd.r)
+	if err != nil {
+		return err
+	}
+	if size > maxMessageSize {
+		return fmt.Errorf("message size %v exceeds the maximum of %v bytes", size, maxMessageSize)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(pd.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// textEncoder encodes messages in the text format,
+// separated by textSeparator lines.
+type textEncoder struct {
+	w       io.Writer
+	encoded bool
+}
+
+func (te *textEncoder) Encode(v interface{}) error {
+	m, err := wireMessage(v)
+	if err != nil {
+		return err
+	}
+	b, err := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if te.encoded {
+		out.WriteString(textSeparator + "\n")
+	}
+	out.Write(b)
+	_, err = io.Copy(te.w, &out)
+	te.encoded = true
+	return err
+}
+
+// textDecoder decodes the messages of a text format stream,
+// separated by textSeparator lines.
+type textDecoder struct {
+	r *bufio.Reader
+
+	// separated is set when the last message was followed by a separator,
+	// another message, possibly empty, coming next.
+	separated bool
+}
+
+func (td *textDecoder) Decode(v interface{}) error {
+	m, err := wireMessage(v)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	read := td.separated
+	td.separated = false
+	for {
+		line, err := td.r.ReadBytes('\n')
+		if len(line) > 0 {
+			read = true
+			if string(bytes.TrimSpace(line)) == textSeparator {
+				td.separated = true
+				break
+			}
+			b = append(b, line...)
+			if len(b) > maxMessageSize {
+				return fmt.Errorf("message exceeds the maximum of %v bytes", maxMessageSize)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !read {
+		return io.EOF
+	}
+	return prototext.Unmarshal(b, m)
+}
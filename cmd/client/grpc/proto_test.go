@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoFormatsRoundTrip(t *testing.T) {
+	values := []string{"first", "second\n---\nline", ""}
+	for _, format := range []string{"proto", "textproto"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := DefaultEncoders[format].NewEncoder(&buf)
+			for _, v := range values {
+				if err := enc.Encode(wrapperspb.String(v)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dec := DefaultDecoders[format].NewDecoder(&buf)
+			for _, want := range values {
+				var m wrapperspb.StringValue
+				if err := dec.Decode(&m); err != nil {
+					t.Fatalf("decode %q: %v", want, err)
+				}
+				if m.Value != want {
+					t.Fatalf("decoded: got %q, want %q", m.Value, want)
+				}
+			}
+			if err := dec.Decode(&wrapperspb.StringValue{}); err != io.EOF {
+				t.Fatalf("decode at end of stream: got %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestProtoFormatsInvalidInput(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := DefaultEncoders["proto"].NewEncoder(&encoded).Encode(wrapperspb.String("value")); err != nil {
+		t.Fatal(err)
+	}
+	oversized := make([]byte, binary.MaxVarintLen64)
+	oversized = oversized[:binary.PutUvarint(oversized, maxMessageSize+1)]
+
+	tests := []struct {
+		name   string
+		format string
+		input  []byte
+	}{
+		{"truncated size", "proto", []byte{0xff}},
+		{"truncated message", "proto", encoded.Bytes()[:encoded.Len()-1]},
+		{"size overflow", "proto", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"oversized", "proto", append(oversized, make([]byte, 16)...)},
+		{"truncated text", "textproto", []byte(`value: "val`)},
+		{"oversized text", "textproto", []byte(strings.Repeat("# comment\n", maxMessageSize/10+1))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := DefaultDecoders[tt.format].NewDecoder(bytes.NewReader(tt.input))
+			err := dec.Decode(&wrapperspb.StringValue{})
+			if err == nil || err == io.EOF {
+				t.Fatalf("got %v, want a decoding error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/mwitkow/grpc-proxy/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ProxyDialOptions returns the dial options of the connections
+// a proxy server forwards its calls to, see NewProxyServer.
+// The calls are routed to the client registered on the hub as target.
+func ProxyDialOptions(target string) []grpc.DialOption {
+	return append(TargetOptions(target), grpc.WithCodec(proxy.Codec()))
+}
+
+// NewProxyServer returns a gRPC server forwarding every call to conn
+// along with its metadata, the messages being passed through as is.
+func NewProxyServer(conn *grpc.ClientConn) *grpc.Server {
+	director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		return metadata.NewOutgoingContext(ctx, md.Copy()), conn, nil
+	}
+	return grpc.NewServer(
+		grpc.CustomCodec(proxy.Codec()),
+		grpc.UnknownServiceHandler(proxy.TransparentHandler(director)),
+	)
+}
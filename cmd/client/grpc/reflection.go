@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorSource resolves the descriptors of the services
+// and messages of the methods called dynamically.
+type DescriptorSource interface {
+	FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error)
+}
+
+// FindMethod returns the descriptor of method, in the form of
+// package.Service/Method, /package.Service/Method or package.Service.Method.
+func FindMethod(source DescriptorSource, method string) (protoreflect.MethodDescriptor, error) {
+	name := strings.TrimPrefix(method, "/")
+	i := strings.LastIndexAny(name, "/.")
+	if i <= 0 || i == len(name)-1 {
+		return nil, fmt.Errorf("invalid method %q: must be in the form of package.Service/Method", method)
+	}
+	service, methodName := name[:i], name[i+1:]
+	d, err := source.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %v: %v", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return nil, fmt.Errorf("service %v has no method %v", service, methodName)
+	}
+	return md, nil
+}
+
+// reflectionSource resolves the descriptors using the server
+// reflection service reached through conn.
+type reflectionSource struct {
+	ctx    context.Context
+	client rpb.ServerReflectionClient
+	files  *protoregistry.Files
+}
+
+// NewReflectionSource returns a DescriptorSource querying the server
+// reflection service of the server reached through conn. Through the hub,
+// the calls are routed like any other, see the hub default routes.
+func NewReflectionSource(ctx context.Context, conn *grpc.ClientConn) DescriptorSource {
+	return &reflectionSource{
+		ctx:    ctx,
+		client: rpb.NewServerReflectionClient(conn),
+		files:  &protoregistry.Files{},
+	}
+}
+
+func (s *reflectionSource) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := s.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	stream, err := s.client.ServerReflectionInfo(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %v", err)
+	}
+	defer stream.CloseSend()
+
+	// The files are returned along with the dependencies
+	// not yet sent on the stream.
+	pending := make(map[string]*descriptorpb.FileDescriptorProto)
+	request := func(req *rpb.ServerReflectionRequest) ([]*descriptorpb.FileDescriptorProto, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("server reflection: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("server reflection: %v", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		var fds []*descriptorpb.FileDescriptorProto
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return nil, fmt.Errorf("server reflection: %v", err)
+			}
+			pending[fd.GetName()] = fd
+			fds = append(fds, fd)
+		}
+		return fds, nil
+	}
+
+	var register func(fd *descriptorpb.FileDescriptorProto) error
+	register = func(fd *descriptorpb.FileDescriptorProto) error {
+		if _, err := s.files.FindFileByPath(fd.GetName()); err == nil {
+			return nil
+		}
+		for _, dep := range fd.GetDependency() {
+			if _, err := s.files.FindFileByPath(dep); err == nil {
+				continue
+			}
+			depFD, ok := pending[dep]
+			if !ok {
+				if _, err := request(&rpb.ServerReflectionRequest{
+					MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+				}); err != nil {
+					return err
+				}
+				if depFD, ok = pending[dep]; !ok {
+					return fmt.Errorf("server reflection: missing file %v", dep)
+				}
+			}
+			if err := register(depFD); err != nil {
+				return err
+			}
+		}
+		f, err := protodesc.NewFile(fd, s.files)
+		if err != nil {
+			return fmt.Errorf("file %v: %v", fd.GetName(), err)
+		}
+		return s.files.RegisterFile(f)
+	}
+
+	fds, err := request(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: string(name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, fd := range fds {
+		if err := register(fd); err != nil {
+			return nil, err
+		}
+	}
+	return s.files.FindDescriptorByName(name)
+}
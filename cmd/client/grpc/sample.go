@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// newSample returns a message of type desc whose fields are set so that
+// its encoding lists them: nested messages are populated, lists hold one
+// element and maps one entry, and the first field of each oneof is set.
+// The fields of the message types of path, the enclosing messages,
+// are left empty so that recursive types end.
+func newSample(desc protoreflect.MessageDescriptor, path map[protoreflect.FullName]bool) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(desc)
+	if desc.FullName() == "google.protobuf.Any" {
+		// An Any is only valid once its type is set.
+		return m
+	}
+	path[desc.FullName()] = true
+	defer delete(path, desc.FullName())
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && oneof.Fields().Get(0) != fd {
+			continue
+		}
+		value := fd
+		if fd.IsMap() {
+			value = fd.MapValue()
+		}
+		if value.Message() != nil && path[value.Message().FullName()] {
+			continue
+		}
+		switch {
+		case fd.IsList():
+			m.Mutable(fd).List().Append(sampleValue(fd, path))
+		case fd.IsMap():
+			key := sampleValue(fd.MapKey(), path).MapKey()
+			m.Mutable(fd).Map().Set(key, sampleValue(fd.MapValue(), path))
+		default:
+			m.Set(fd, sampleValue(fd, path))
+		}
+	}
+	return m
+}
+
+// sampleValue returns the sample value of a single element of fd.
+func sampleValue(fd protoreflect.FieldDescriptor, path map[protoreflect.FullName]bool) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoreflect.ValueOfMessage(newSample(fd.Message(), path))
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(fd.Enum().Values().Get(0).Number())
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(false)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(0)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(0)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(0)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(0)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(0)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(0)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{})
+	}
+	return protoreflect.ValueOfString("")
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"encoding/xml"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// Status is the rendering of the error status of a failed call,
+// encoded using the response format.
+type Status struct {
+	XMLName xml.Name `json:"-" yaml:"-" xml:"status"`
+
+	Code    int            `json:"code" yaml:"code" xml:"code"`
+	Status  string         `json:"status" yaml:"status" xml:"name"`
+	Message string         `json:"message" yaml:"message" xml:"message"`
+	Details []StatusDetail `json:"details,omitempty" yaml:"details,omitempty" xml:"details>detail,omitempty"`
+
+	// status is encoded as is by the proto formats.
+	status *status.Status
+}
+
+// StatusDetail is the rendering of a detail of an error status.
+// Only the fields of its type are set.
+type StatusDetail struct {
+	Type string `json:"type" yaml:"type" xml:"type,attr"`
+
+	// ErrorInfo
+	Reason   string   `json:"reason,omitempty" yaml:"reason,omitempty" xml:"reason,omitempty"`
+	Domain   string   `json:"domain,omitempty" yaml:"domain,omitempty" xml:"domain,omitempty"`
+	Metadata Metadata `json:"metadata,omitempty" yaml:"metadata,omitempty" xml:"metadata,omitempty"`
+
+	// RetryInfo
+	RetryDelay string `json:"retryDelay,omitempty" yaml:"retryDelay,omitempty" xml:"retryDelay,omitempty"`
+
+	// Other types
+	Value string `json:"value,omitempty" yaml:"value,omitempty" xml:"value,omitempty"`
+}
+
+// Metadata is a string map that can be encoded as XML.
+type Metadata map[string]string
+
+// MarshalXML implements the xml.Marshaler interface,
+// encoding the entries sorted by key.
+func (m Metadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		entry := xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}},
+		}
+		if err := e.EncodeElement(m[k], entry); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// NewStatus returns the rendering of s.
+func NewStatus(s *status.Status) *Status {
+	out := &Status{
+		Code:    int(s.Code()),
+		Status:  s.Code().String(),
+		Message: s.Message(),
+		status:  s,
+	}
+	for _, d := range s.Details() {
+		out.Details = append(out.Details, newStatusDetail(d))
+	}
+	return out
+}
+
+func newStatusDetail(d interface{}) StatusDetail {
+	switch d := d.(type) {
+	case *errdetails.ErrorInfo:
+		return StatusDetail{
+			Type:     proto.MessageName(d),
+			Reason:   d.GetReason(),
+			Domain:   d.GetDomain(),
+			Metadata: d.GetMetadata(),
+		}
+	case *errdetails.RetryInfo:
+		detail := StatusDetail{Type: proto.MessageName(d)}
+		if delay, err := ptypes.Duration(d.GetRetryDelay()); err == nil {
+			detail.RetryDelay = delay.String()
+		}
+		return detail
+	case proto.Message:
+		return StatusDetail{Type: proto.MessageName(d), Value: proto.CompactTextString(d)}
+	case error:
+		// The detail could not be unmarshaled.
+		return StatusDetail{Type: "error", Value: d.Error()}
+	}
+	return StatusDetail{Type: "unknown"}
+}
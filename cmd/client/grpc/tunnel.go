@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	ws "github.com/devodev/grpc-demo/internal/websocket"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// tunnelOptions dials the hub caller websocket and returns the
+// dial options that route gRPC connections through it.
+//
+// The websocket is wrapped in a yamux server session on which
+// every gRPC connection is a new stream. TLS options apply to the
+// websocket itself when using the wss scheme, the tunneled gRPC
+// connections being plaintext within it.
+func (d *Dialer) tunnelOptions() ([]grpc.DialOption, error) {
+	u, err := url.Parse(d.HubWSAddr)
+	if err != nil {
+		return nil, fmt.Errorf("hub ws uri: %v", err)
+	}
+	dialer := &websocket.Dialer{
+		Proxy:            websocket.DefaultDialer.Proxy,
+		HandshakeTimeout: d.Timeout,
+	}
+	creds := tunnelCredentials{level: credentials.NoSecurity}
+	switch u.Scheme {
+	case "wss":
+		creds.level = credentials.PrivacyAndIntegrity
+		tlsConfig, err := d.tlsConfig(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		dialer.TLSClientConfig = tlsConfig
+	case "ws":
+		if d.hasPerRPCCredentials() {
+			return nil, fmt.Errorf("hub ws uri: credentials require the wss scheme")
+		}
+	default:
+		return nil, fmt.Errorf("hub ws uri: unsupported scheme %q", u.Scheme)
+	}
+
+	header := make(http.Header)
+	ws.SetReadLimitHeader(header, 0)
+	wsConn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		return nil, fmt.Errorf("hub ws dial: %v", err)
+	}
+	wsRwc, err := ws.ReadWriteCloser(wsConn, ws.WithPeerReadLimit(resp.Header))
+	if err != nil {
+		wsConn.Close()
+		return nil, err
+	}
+	session, err := yamux.Server(wsRwc, yamux.DefaultConfig())
+	if err != nil {
+		wsRwc.CloseWithMessage(err.Error())
+		return nil, err
+	}
+	d.tunnel = session
+	d.tunnelHost = u.Host
+
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return session.Open()
+		}),
+	}, nil
+}
+
+// hasPerRPCCredentials reports whether per-RPC credentials requiring
+// transport security are set.
+func (d *Dialer) hasPerRPCCredentials() bool {
+	return (d.AuthToken != "" && !d.InsecureAuth) || d.JWTKey != "" || d.JWTKeyFile != ""
+}
+
+// tunnelCredentials are the transport credentials of gRPC connections
+// tunneled through a websocket. They perform no handshake and report
+// the security level of the websocket connection, so that per-RPC
+// credentials can be used over wss.
+type tunnelCredentials struct {
+	level credentials.SecurityLevel
+}
+
+type tunnelAuthInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (tunnelAuthInfo) AuthType() string {
+	return "websocket"
+}
+
+func (c tunnelCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, tunnelAuthInfo{credentials.CommonAuthInfo{SecurityLevel: c.level}}, nil
+}
+
+func (c tunnelCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, fmt.Errorf("tunnel credentials are client side only")
+}
+
+func (c tunnelCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "websocket"}
+}
+
+func (c tunnelCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (c tunnelCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/devodev/grpc-demo/internal/hub"
+)
+
+// fileConfig is the YAML configuration file of the hub.
+//
+// Empty values keep the value provided by the environment
+// or the defaults. Command-line flags take precedence over the file.
+type fileConfig struct {
+	Listeners struct {
+		HTTP   string `yaml:"http"`
+		GRPC   string `yaml:"grpc"`
+		Tunnel string `yaml:"tunnel"`
+	} `yaml:"listeners"`
+
+	TLS struct {
+		Enabled    *bool  `yaml:"enabled"`
+		CACertFile string `yaml:"caCertFile"`
+		CertFile   string `yaml:"certFile"`
+		KeyFile    string `yaml:"keyFile"`
+	} `yaml:"tls"`
+
+	Enrollment struct {
+		CACertFile string        `yaml:"caCertFile"`
+		CAKeyFile  string        `yaml:"caKeyFile"`
+		CertTTL    time.Duration `yaml:"certTTL"`
+
+		AllowUncertifiedAgents *bool `yaml:"allowUncertifiedAgents"`
+	} `yaml:"enrollment"`
+
+	Timeouts struct {
+		HTTPRead  time.Duration `yaml:"httpRead"`
+		HTTPWrite time.Duration `yaml:"httpWrite"`
+		HTTPIdle  time.Duration `yaml:"httpIdle"`
+		Shutdown  time.Duration `yaml:"shutdown"`
+
+		ReconnectGracePeriod time.Duration `yaml:"reconnectGracePeriod"`
+	} `yaml:"timeouts"`
+
+	Auth struct {
+		Tokens []string `yaml:"tokens"`
+	} `yaml:"auth"`
+
+	GRPCWeb struct {
+		AllowedOrigins []string `yaml:"allowedOrigins"`
+	} `yaml:"grpcWeb"`
+
+	Limits struct {
+		GRPCMaxMessageSize int `yaml:"grpcMaxMessageSize"`
+
+		Websocket struct {
+			ReadLimit            int64 `yaml:"readLimit"`
+			MaxFrameSize         int   `yaml:"maxFrameSize"`
+			WriteCoalescing      *bool `yaml:"writeCoalescing"`
+			Compression          *bool `yaml:"compression"`
+			CompressionLevel     *int  `yaml:"compressionLevel"`
+			CompressionThreshold int   `yaml:"compressionThreshold"`
+		} `yaml:"websocket"`
+
+		Session struct {
+			AcceptBacklog     int           `yaml:"acceptBacklog"`
+			KeepAliveInterval time.Duration `yaml:"keepAliveInterval"`
+			DisableKeepAlive  *bool         `yaml:"disableKeepAlive"`
+			WriteTimeout      time.Duration `yaml:"writeTimeout"`
+			MaxStreamWindow   uint32        `yaml:"maxStreamWindow"`
+		} `yaml:"session"`
+	} `yaml:"limits"`
+
+	Routing []struct {
+		Method   string            `yaml:"method"`
+		Target   string            `yaml:"target"`
+		Agent    string            `yaml:"agent"`
+		Labels   map[string]string `yaml:"labels"`
+		Upstream string            `yaml:"upstream"`
+	} `yaml:"routing"`
+
+	AuthorityRouting struct {
+		Pattern string `yaml:"pattern"`
+	} `yaml:"authorityRouting"`
+
+	Upstreams []struct {
+		Name    string            `yaml:"name"`
+		Address string            `yaml:"address"`
+		Labels  map[string]string `yaml:"labels"`
+		TLS     *struct {
+			CACertFile         string `yaml:"caCertFile"`
+			CertFile           string `yaml:"certFile"`
+			KeyFile            string `yaml:"keyFile"`
+			ServerName         string `yaml:"serverName"`
+			InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+		} `yaml:"tls"`
+	} `yaml:"upstreams"`
+
+	Log struct {
+		Level string `yaml:"level"`
+	} `yaml:"log"`
+}
+
+// loadConfigFile reads the configuration file at path.
+// Unknown keys are rejected.
+func loadConfigFile(path string) (*fileConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %v", err)
+	}
+	var fc fileConfig
+	if err := yaml.UnmarshalStrict(b, &fc); err != nil {
+		return nil, fmt.Errorf("config file %v: %v", path, err)
+	}
+	return &fc, nil
+}
+
+// apply sets the values of fc on c, except the ones whose flag is set.
+func (fc *fileConfig) apply(c *serverConfig, flagSet func(name string) bool) {
+	str := func(dst *string, v, flag string) {
+		if v != "" && !flagSet(flag) {
+			*dst = v
+		}
+	}
+	strs := func(dst *[]string, v []string, flag string) {
+		if len(v) > 0 && !flagSet(flag) {
+			*dst = v
+		}
+	}
+	// Booleans are pointers so that false overrides the environment.
+	boolean := func(dst *bool, v *bool, flag string) {
+		if v != nil && !flagSet(flag) {
+			*dst = *v
+		}
+	}
+	integer := func(dst *int, v int, flag string) {
+		if v != 0 && !flagSet(flag) {
+			*dst = v
+		}
+	}
+	duration := func(dst *time.Duration, v time.Duration, flag string) {
+		if v != 0 && !flagSet(flag) {
+			*dst = v
+		}
+	}
+
+	str(&c.HTTPListenAddr, fc.Listeners.HTTP, "http-listen")
+	str(&c.GRPCListenAddr, fc.Listeners.GRPC, "grpc-listen")
+	str(&c.TunnelListenAddr, fc.Listeners.Tunnel, "tunnel-listen")
+
+	boolean(&c.TLS, fc.TLS.Enabled, "tls")
+	str(&c.CACertFile, fc.TLS.CACertFile, "tls-ca-cert-file")
+	str(&c.CertFile, fc.TLS.CertFile, "tls-cert-file")
+	str(&c.KeyFile, fc.TLS.KeyFile, "tls-key-file")
+
+	str(&c.EnrollCACert, fc.Enrollment.CACertFile, "enrollment-ca-cert-file")
+	str(&c.EnrollCAKey, fc.Enrollment.CAKeyFile, "enrollment-ca-key-file")
+	duration(&c.EnrollCertTTL, fc.Enrollment.CertTTL, "enrollment-cert-ttl")
+	boolean(&c.EnrollUncertified, fc.Enrollment.AllowUncertifiedAgents, "enrollment-allow-uncertified-agents")
+
+	duration(&c.HTTPReadTimeout, fc.Timeouts.HTTPRead, "http-read-timeout")
+	duration(&c.HTTPWriteTimeout, fc.Timeouts.HTTPWrite, "http-write-timeout")
+	duration(&c.HTTPIdleTimeout, fc.Timeouts.HTTPIdle, "http-idle-timeout")
+	duration(&c.ShutdownTimeout, fc.Timeouts.Shutdown, "shutdown-timeout")
+	duration(&c.ReconnectGrace, fc.Timeouts.ReconnectGracePeriod, "reconnect-grace-period")
+
+	strs(&c.AuthTokens, fc.Auth.Tokens, "auth-token")
+	strs(&c.GRPCWebOrigins, fc.GRPCWeb.AllowedOrigins, "grpc-web-allowed-origin")
+
+	integer(&c.GRPCMaxMsgSize, fc.Limits.GRPCMaxMessageSize, "grpc-max-message-size")
+
+	wsLimits := fc.Limits.Websocket
+	if wsLimits.ReadLimit != 0 && !flagSet("ws-read-limit") {
+		c.WSReadLimit = wsLimits.ReadLimit
+	}
+	integer(&c.WSMaxFrameSize, wsLimits.MaxFrameSize, "ws-max-frame-size")
+	boolean(&c.WSCoalescing, wsLimits.WriteCoalescing, "ws-write-coalescing")
+	boolean(&c.WSCompression, wsLimits.Compression, "ws-compression")
+	if wsLimits.CompressionLevel != nil && !flagSet("ws-compression-level") {
+		c.WSCompressionLvl = *wsLimits.CompressionLevel
+	}
+	integer(&c.WSCompressionMin, wsLimits.CompressionThreshold, "ws-compression-threshold")
+
+	session := fc.Limits.Session
+	integer(&c.SessionAcceptBacklog, session.AcceptBacklog, "session-accept-backlog")
+	duration(&c.SessionKeepAlive, session.KeepAliveInterval, "session-keepalive-interval")
+	boolean(&c.SessionDisableKeepAlive, session.DisableKeepAlive, "session-disable-keepalive")
+	duration(&c.SessionWriteTimeout, session.WriteTimeout, "session-write-timeout")
+	if session.MaxStreamWindow != 0 && !flagSet("session-max-stream-window") {
+		c.SessionMaxStreamWindow = session.MaxStreamWindow
+	}
+
+	if len(fc.Routing) > 0 && !flagSet("route") {
+		c.Routes = nil
+		for _, r := range fc.Routing {
+			c.Routes = append(c.Routes, hub.Route{
+				Method:   r.Method,
+				Target:   r.Target,
+				Agent:    r.Agent,
+				Labels:   r.Labels,
+				Upstream: r.Upstream,
+			})
+		}
+	}
+
+	str(&c.AuthorityPattern, fc.AuthorityRouting.Pattern, "authority-pattern")
+
+	if len(fc.Upstreams) > 0 && !flagSet("upstream") {
+		c.Upstreams = nil
+		for _, u := range fc.Upstreams {
+			upstream := hub.Upstream{Name: u.Name, Address: u.Address, Labels: u.Labels}
+			if u.TLS != nil {
+				upstream.TLS = &hub.UpstreamTLS{
+					CACertFile:         u.TLS.CACertFile,
+					CertFile:           u.TLS.CertFile,
+					KeyFile:            u.TLS.KeyFile,
+					ServerName:         u.TLS.ServerName,
+					InsecureSkipVerify: u.TLS.InsecureSkipVerify,
+				}
+			}
+			c.Upstreams = append(c.Upstreams, upstream)
+		}
+	}
+
+	str(&c.LogLevel, fc.Log.Level, "log-level")
+}
+
+// resolveConfig returns base updated with the configuration file, if any.
+func resolveConfig(base serverConfig, flagSet func(name string) bool) (serverConfig, error) {
+	cfg := base
+	if cfg.ConfigFile == "" {
+		return cfg, nil
+	}
+	fc, err := loadConfigFile(cfg.ConfigFile)
+	if err != nil {
+		return cfg, err
+	}
+	fc.apply(&cfg, flagSet)
+	return cfg, nil
+}
+
+func newCommandConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "hub configuration file.",
+	}
+	cmd.AddCommand(
+		newCommandConfigValidate(),
+	)
+	return cmd
+}
+
+func newCommandConfigValidate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [file]",
+		Short: "validate a hub configuration file.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var cfg serverConfig
+			envconfig.Process("", &cfg)
+			cfg.ConfigFile = args[0]
+			cfg, err := resolveConfig(cfg, func(string) bool { return false })
+			if err != nil {
+				return err
+			}
+			res, err := loadResources(cfg)
+			if err != nil {
+				return err
+			}
+			opts, err := hubOptions(cfg, res)
+			if err != nil {
+				return err
+			}
+			if err := hub.Validate(opts...); err != nil {
+				return err
+			}
+			writeOut(fmt.Sprintf("%v: ok", args[0]))
+			return nil
+		},
+	}
+	return cmd
+}
+
+// tlsChanged reports whether the TLS settings of a and b differ.
+func tlsChanged(a, b serverConfig) bool {
+	return a.TLS != b.TLS || a.CACertFile != b.CACertFile || a.CertFile != b.CertFile || a.KeyFile != b.KeyFile
+}
+
+// authorityChanged reports whether the enrollment CA files of a and b differ.
+func authorityChanged(a, b serverConfig) bool {
+	return a.EnrollCACert != b.EnrollCACert || a.EnrollCAKey != b.EnrollCAKey
+}
@@ -18,6 +18,7 @@ func Execute() {
 	rootCmd := newCommandRoot()
 	rootCmd.AddCommand(
 		newCommandServe(),
+		newCommandConfig(),
 	)
 	if err := rootCmd.Execute(); err != nil {
 		log.Println(err)
@@ -2,68 +2,262 @@ package cmd
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/cobra"
 
+	"github.com/devodev/grpc-demo/internal/certs"
 	"github.com/devodev/grpc-demo/internal/hub"
 )
 
 // serverConfig holds serverConfig for the Fluentd command.
 type serverConfig struct {
-	HTTPListenAddr string `envconfig:"HTTP_LISTEN_ADDR" default:":8080"`
-	GRPCListenAddr string `envconfig:"GRPC_LISTEN_ADDR" default:":9090"`
-	TLS            bool   `envconfig:"TLS"`
-	CACertFile     string `envconfig:"TLS_CA_CERT_FILE"`
-	CertFile       string `envconfig:"TLS_CERT_FILE"`
-	KeyFile        string `envconfig:"TLS_KEY_FILE"`
+	ConfigFile        string        `envconfig:"CONFIG_FILE"`
+	LogLevel          string        `envconfig:"LOG_LEVEL" default:"info"`
+	HTTPListenAddr    string        `envconfig:"HTTP_LISTEN_ADDR" default:":8080"`
+	GRPCListenAddr    string        `envconfig:"GRPC_LISTEN_ADDR" default:":9090"`
+	TunnelListenAddr  string        `envconfig:"TUNNEL_LISTEN_ADDR"`
+	TLS               bool          `envconfig:"TLS"`
+	CACertFile        string        `envconfig:"TLS_CA_CERT_FILE"`
+	CertFile          string        `envconfig:"TLS_CERT_FILE"`
+	KeyFile           string        `envconfig:"TLS_KEY_FILE"`
+	EnrollCACert      string        `envconfig:"ENROLLMENT_CA_CERT_FILE"`
+	EnrollCAKey       string        `envconfig:"ENROLLMENT_CA_KEY_FILE"`
+	EnrollCertTTL     time.Duration `envconfig:"ENROLLMENT_CERT_TTL"`
+	EnrollUncertified bool          `envconfig:"ENROLLMENT_ALLOW_UNCERTIFIED_AGENTS"`
+	AuthTokens        []string      `envconfig:"AUTH_TOKENS"`
+	GRPCWebOrigins    []string      `envconfig:"GRPC_WEB_ALLOWED_ORIGINS"`
+	GRPCMaxMsgSize    int           `envconfig:"GRPC_MAX_MESSAGE_SIZE"`
+	WSReadLimit       int64         `envconfig:"WS_READ_LIMIT"`
+	WSMaxFrameSize    int           `envconfig:"WS_MAX_FRAME_SIZE"`
+	WSCoalescing      bool          `envconfig:"WS_WRITE_COALESCING"`
+	WSCompression     bool          `envconfig:"WS_COMPRESSION"`
+	WSCompressionLvl  int           `envconfig:"WS_COMPRESSION_LEVEL" default:"1"`
+	WSCompressionMin  int           `envconfig:"WS_COMPRESSION_THRESHOLD"`
+
+	SessionAcceptBacklog    int           `envconfig:"SESSION_ACCEPT_BACKLOG"`
+	SessionKeepAlive        time.Duration `envconfig:"SESSION_KEEPALIVE_INTERVAL"`
+	SessionDisableKeepAlive bool          `envconfig:"SESSION_DISABLE_KEEPALIVE"`
+	SessionWriteTimeout     time.Duration `envconfig:"SESSION_WRITE_TIMEOUT"`
+	SessionMaxStreamWindow  uint32        `envconfig:"SESSION_MAX_STREAM_WINDOW"`
+
+	HTTPReadTimeout  time.Duration  `envconfig:"HTTP_READ_TIMEOUT" default:"5s"`
+	HTTPWriteTimeout time.Duration  `envconfig:"HTTP_WRITE_TIMEOUT" default:"10s"`
+	HTTPIdleTimeout  time.Duration  `envconfig:"HTTP_IDLE_TIMEOUT" default:"15s"`
+	ShutdownTimeout  time.Duration  `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	ReconnectGrace   time.Duration  `envconfig:"RECONNECT_GRACE_PERIOD"`
+	Routes           []hub.Route    `ignored:"true"`
+	AuthorityPattern string         `envconfig:"AUTHORITY_PATTERN"`
+	Upstreams        []hub.Upstream `ignored:"true"`
 }
 
 // setupCmd sets flags on the provided cmd and resolve env variables using the provided Config.
 func setupCmd(cmd *cobra.Command, c *serverConfig) *cobra.Command {
 	envconfig.Process("", c)
+	cmd.Flags().StringVar(&c.ConfigFile, "config", c.ConfigFile, "YAML configuration file; reloaded on SIGHUP. Flags take precedence over the file.")
+	cmd.Flags().StringVar(&c.LogLevel, "log-level", c.LogLevel, "log level: debug, info or error")
 	cmd.Flags().StringVar(&c.HTTPListenAddr, "http-listen", c.HTTPListenAddr, "HTTP server listening address.")
 	cmd.Flags().StringVar(&c.GRPCListenAddr, "grpc-listen", c.GRPCListenAddr, "GRPC server listening address.")
+	cmd.Flags().DurationVar(&c.HTTPReadTimeout, "http-read-timeout", c.HTTPReadTimeout, "HTTP server read timeout")
+	cmd.Flags().DurationVar(&c.HTTPWriteTimeout, "http-write-timeout", c.HTTPWriteTimeout, "HTTP server write timeout; streamed responses, such as the activity feed, are not bounded")
+	cmd.Flags().DurationVar(&c.HTTPIdleTimeout, "http-idle-timeout", c.HTTPIdleTimeout, "HTTP server idle timeout")
+	cmd.Flags().DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed to drain in-flight calls on shutdown before closing the sessions")
+	cmd.Flags().DurationVar(&c.ReconnectGrace, "reconnect-grace-period", c.ReconnectGrace, "time during which the name of a disconnected agent stays reserved for it, proxied calls waiting for it to reconnect; 0 to release it right away")
+	cmd.Flags().StringVar(&c.TunnelListenAddr, "tunnel-listen", c.TunnelListenAddr, "tunnel listener address accepting tls:// and h2:// registrations (requires --tls).")
 	cmd.Flags().BoolVar(&c.TLS, "tls", c.TLS, "enable tls")
-	cmd.Flags().StringVar(&c.CACertFile, "tls-ca-cert-file", c.CACertFile, "ca certificate file")
+	cmd.Flags().StringVar(&c.CACertFile, "tls-ca-cert-file", c.CACertFile, "ca certificate file verifying the client certificates presented to the hub; reloaded when it changes")
 	cmd.Flags().StringVar(&c.CertFile, "tls-cert-file", c.CertFile, "certificate file")
 	cmd.Flags().StringVar(&c.KeyFile, "tls-key-file", c.KeyFile, "key file")
+	cmd.Flags().StringVar(&c.EnrollCACert, "enrollment-ca-cert-file", c.EnrollCACert, "certificate file of the CA signing the client certificates of enrolled agents (requires --tls)")
+	cmd.Flags().StringVar(&c.EnrollCAKey, "enrollment-ca-key-file", c.EnrollCAKey, "key file of the CA signing the client certificates of enrolled agents")
+	cmd.Flags().DurationVar(&c.EnrollCertTTL, "enrollment-cert-ttl", c.EnrollCertTTL, "lifetime of the client certificates of enrolled agents; 0 for default (24h)")
+	cmd.Flags().BoolVar(&c.EnrollUncertified, "enrollment-allow-uncertified-agents", c.EnrollUncertified, "INSECURE: let agents without a client certificate register under names not bound to one, such as while migrating agents to enrollment")
+	cmd.Flags().StringSliceVar(&c.GRPCWebOrigins, "grpc-web-allowed-origin", c.GRPCWebOrigins, "origin allowed to make cross-origin gRPC-Web requests (repeatable); use \"*\" to allow any")
+	cmd.Flags().IntVar(&c.GRPCMaxMsgSize, "grpc-max-message-size", c.GRPCMaxMsgSize, "maximum size of a gRPC message, including proxied ones; 0 for default (4MiB)")
+	cmd.Flags().Int64Var(&c.WSReadLimit, "ws-read-limit", c.WSReadLimit, "maximum size of a websocket message read from peers; 0 for default (1MiB)")
+	cmd.Flags().IntVar(&c.WSMaxFrameSize, "ws-max-frame-size", c.WSMaxFrameSize, "maximum size of a websocket message written to peers; 0 for default (32KiB)")
+	cmd.Flags().BoolVar(&c.WSCoalescing, "ws-write-coalescing", c.WSCoalescing, "coalesce small writes into fewer websocket messages")
+	cmd.Flags().BoolVar(&c.WSCompression, "ws-compression", c.WSCompression, "accept websocket per-message compression (permessage-deflate) from peers")
+	cmd.Flags().IntVar(&c.WSCompressionLvl, "ws-compression-level", c.WSCompressionLvl, "websocket compression level, from -2 (huffman only) to 9 (best compression)")
+	cmd.Flags().IntVar(&c.WSCompressionMin, "ws-compression-threshold", c.WSCompressionMin, "minimum size of a compressed websocket message; 0 for default (256B)")
+	cmd.Flags().IntVar(&c.SessionAcceptBacklog, "session-accept-backlog", c.SessionAcceptBacklog, "maximum number of yamux streams waiting to be accepted; 0 for default (256)")
+	cmd.Flags().DurationVar(&c.SessionKeepAlive, "session-keepalive-interval", c.SessionKeepAlive, "yamux keep-alive interval; 0 for default (30s)")
+	cmd.Flags().BoolVar(&c.SessionDisableKeepAlive, "session-disable-keepalive", c.SessionDisableKeepAlive, "disable yamux keep-alives")
+	cmd.Flags().DurationVar(&c.SessionWriteTimeout, "session-write-timeout", c.SessionWriteTimeout, "yamux connection write timeout; 0 for default (10s)")
+	cmd.Flags().Uint32Var(&c.SessionMaxStreamWindow, "session-max-stream-window", c.SessionMaxStreamWindow, "maximum yamux stream window size in bytes; 0 for default (256KiB)")
+	cmd.Flags().Var((*routesValue)(&c.Routes), "route", "route of the calls not served by the hub, in the form of method=target[:argument] (repeatable, first match wins); targets: local, metadata, agent:<name>, labels:<key=value,...> or upstream:<host:port>")
+	cmd.Flags().StringVar(&c.AuthorityPattern, "authority-pattern", c.AuthorityPattern, "pattern deriving the client name from the :authority of the calls routed using the metadata, such as \"*.hub.local\"; falls back to the name metadata key")
+	cmd.Flags().Var((*upstreamsValue)(&c.Upstreams), "upstream", "plaintext gRPC server registered alongside the agents, in the form of name=host:port (repeatable); use the configuration file for TLS")
+	cmd.Flags().StringSliceVar(&c.AuthTokens, "auth-token", c.AuthTokens, "bearer token accepted from callers (repeatable); no authentication if empty")
 	return cmd
 }
 
+// routesValue is a repeatable flag parsing routes, see hub.ParseRoute.
+type routesValue []hub.Route
+
+func (v *routesValue) String() string {
+	routes := make([]string, 0, len(*v))
+	for _, r := range *v {
+		routes = append(routes, r.String())
+	}
+	return "[" + strings.Join(routes, " ") + "]"
+}
+
+func (v *routesValue) Set(s string) error {
+	r, err := hub.ParseRoute(s)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, r)
+	return nil
+}
+
+func (v *routesValue) Type() string {
+	return "route"
+}
+
+// upstreamsValue is a repeatable flag parsing name=host:port upstreams.
+type upstreamsValue []hub.Upstream
+
+func (v *upstreamsValue) String() string {
+	upstreams := make([]string, 0, len(*v))
+	for _, u := range *v {
+		upstreams = append(upstreams, u.Name+"="+u.Address)
+	}
+	return "[" + strings.Join(upstreams, " ") + "]"
+}
+
+func (v *upstreamsValue) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid upstream %q: must be name=host:port", s)
+	}
+	*v = append(*v, hub.Upstream{Name: parts[0], Address: parts[1]})
+	return nil
+}
+
+func (v *upstreamsValue) Type() string {
+	return "upstream"
+}
+
 func makeTLSConfig(caPath, certPath, keyPath string) (*tls.Config, error) {
-	tlsConfig := &tls.Config{}
-	tlsConfig.PreferServerCipherSuites = true
-	if caPath != "" {
-		cacert, err := ioutil.ReadFile(caPath)
+	if certPath == "" {
+		return nil, fmt.Errorf("missing cert file")
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("missing key file")
+	}
+	// The files are watched so that rotated certificates are served
+	// to new connections without restarting the hub.
+	reloader, err := certs.NewReloader(certPath, keyPath, caPath, certs.WithReloadHandler(func(err error) {
 		if err != nil {
-			return nil, fmt.Errorf("ca cert: %v", err)
+			log.Printf("tls: reloading certificates failed, keeping the current ones: %v", err)
+			return
 		}
-		certpool := x509.NewCertPool()
-		certpool.AppendCertsFromPEM(cacert)
-		tlsConfig.RootCAs = certpool
+		log.Printf("tls: certificates reloaded")
+	}))
+	if err != nil {
+		return nil, err
 	}
+	return reloader.ServerConfig(&tls.Config{PreferServerCipherSuites: true}), nil
+}
+
+func makeAuthority(certPath, keyPath string) (*certs.Authority, error) {
 	if certPath == "" {
-		return nil, fmt.Errorf("missing cert file")
+		return nil, fmt.Errorf("missing enrollment ca cert file")
 	}
 	if keyPath == "" {
-		return nil, fmt.Errorf("missing key file")
+		return nil, fmt.Errorf("missing enrollment ca key file")
 	}
-	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	return certs.LoadAuthority(certPath, keyPath)
+}
+
+// hubResources holds the resources loaded from the files
+// referenced by the configuration.
+type hubResources struct {
+	tlsConfig *tls.Config
+	authority *certs.Authority
+}
+
+// loadResources loads the resources referenced by c.
+func loadResources(c serverConfig) (hubResources, error) {
+	var res hubResources
+	var err error
+	if c.TLS {
+		res.tlsConfig, err = makeTLSConfig(c.CACertFile, c.CertFile, c.KeyFile)
+		if err != nil {
+			return res, err
+		}
+	}
+	if c.EnrollCACert != "" || c.EnrollCAKey != "" {
+		res.authority, err = makeAuthority(c.EnrollCACert, c.EnrollCAKey)
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// hubOptions returns the hub options matching c.
+func hubOptions(c serverConfig, res hubResources) ([]hub.Option, error) {
+	logLevel, err := hub.ParseLogLevel(c.LogLevel)
 	if err != nil {
-		return nil, fmt.Errorf("cert/key: %v", err)
+		return nil, err
+	}
+	opts := []hub.Option{
+		hub.WithLogLevel(logLevel),
+		hub.WithHTTPListenAddr(c.HTTPListenAddr),
+		hub.WithGRPCListenAddr(c.GRPCListenAddr),
+		hub.WithTimeouts(c.HTTPReadTimeout, c.HTTPWriteTimeout, c.HTTPIdleTimeout),
+		hub.WithShutdownTimeout(c.ShutdownTimeout),
+		hub.WithReconnectGracePeriod(c.ReconnectGrace),
+		hub.WithGRPCWebAllowedOrigins(c.GRPCWebOrigins...),
+		hub.WithTunnelListenAddr(c.TunnelListenAddr),
+		hub.WithGRPCMaxMessageSize(c.GRPCMaxMsgSize),
+		hub.WithWebsocketLimits(c.WSReadLimit, c.WSMaxFrameSize),
+		hub.WithWebsocketWriteCoalescing(c.WSCoalescing),
+		hub.WithSessionConfig(hub.SessionConfig{
+			AcceptBacklog:          c.SessionAcceptBacklog,
+			KeepAliveInterval:      c.SessionKeepAlive,
+			DisableKeepAlive:       c.SessionDisableKeepAlive,
+			ConnectionWriteTimeout: c.SessionWriteTimeout,
+			MaxStreamWindowSize:    c.SessionMaxStreamWindow,
+		}),
+	}
+	if c.WSCompression {
+		opts = append(opts, hub.WithWebsocketCompression(c.WSCompressionLvl, c.WSCompressionMin))
+	}
+	if len(c.Routes) > 0 {
+		opts = append(opts, hub.WithRoutes(c.Routes...))
+	}
+	if c.AuthorityPattern != "" {
+		opts = append(opts, hub.WithAuthorityPattern(c.AuthorityPattern))
+	}
+	if len(c.Upstreams) > 0 {
+		opts = append(opts, hub.WithUpstreams(c.Upstreams...))
+	}
+	if len(c.AuthTokens) > 0 {
+		opts = append(opts, hub.WithAuthFunc(hub.TokenAuth(c.AuthTokens...)))
+	}
+	if res.tlsConfig != nil {
+		opts = append(opts, hub.WithTLSConfig(res.tlsConfig))
 	}
-	tlsConfig.Certificates = []tls.Certificate{pair}
-	return tlsConfig, nil
+	if res.authority != nil {
+		opts = append(opts, hub.WithCertificateAuthority(res.authority, c.EnrollCertTTL))
+		opts = append(opts, hub.WithAllowUncertifiedAgents(c.EnrollUncertified))
+	}
+	return opts, nil
 }
 
 func newCommandServe() *cobra.Command {
-	var cfg serverConfig
+	var base serverConfig
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "serve the gRPC hub.",
@@ -71,17 +265,20 @@ func newCommandServe() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			quit := make(chan os.Signal, 1)
 			signal.Notify(quit, os.Interrupt)
+			reload := make(chan os.Signal, 1)
+			signal.Notify(reload, syscall.SIGHUP)
 
-			hubOpts := []hub.Option{
-				hub.WithHTTPListenAddr(cfg.HTTPListenAddr),
-				hub.WithGRPCListenAddr(cfg.GRPCListenAddr),
+			cfg, err := resolveConfig(base, cmd.Flags().Changed)
+			if err != nil {
+				return err
 			}
-			if cfg.TLS {
-				tlsConfig, err := makeTLSConfig(cfg.CACertFile, cfg.CertFile, cfg.KeyFile)
-				if err != nil {
-					return err
-				}
-				hubOpts = append(hubOpts, hub.WithTLSConfig(tlsConfig))
+			res, err := loadResources(cfg)
+			if err != nil {
+				return err
+			}
+			hubOpts, err := hubOptions(cfg, res)
+			if err != nil {
+				return err
 			}
 
 			h, err := hub.New(hubOpts...)
@@ -89,12 +286,49 @@ func newCommandServe() *cobra.Command {
 				return err
 			}
 
-			select {
-			case <-quit:
-				h.Close()
+			for {
+				select {
+				case <-quit:
+					h.Close()
+					return nil
+				case <-reload:
+					next, err := reloadConfig(base, cfg, cmd.Flags().Changed)
+					if err != nil {
+						// Report the error through Reload so that it reaches the activity feed.
+						h.Reload(func(*hub.Hub) error { return err })
+						continue
+					}
+					opts, err := hubOptions(next, res)
+					if err != nil {
+						h.Reload(func(*hub.Hub) error { return err })
+						continue
+					}
+					if err := h.Reload(opts...); err == nil {
+						cfg = next
+					}
+				}
 			}
-			return nil
 		},
 	}
-	return setupCmd(cmd, &cfg)
+	return setupCmd(cmd, &base)
+}
+
+// reloadConfig resolves the configuration again from base and the
+// configuration file. The TLS configuration and the certificate authority
+// require a restart, so the settings referencing their files are kept and
+// the hub resources loaded from them stay in use.
+func reloadConfig(base, current serverConfig, flagSet func(string) bool) (serverConfig, error) {
+	next, err := resolveConfig(base, flagSet)
+	if err != nil {
+		return next, err
+	}
+	if tlsChanged(current, next) {
+		log.Printf("tls: configuration changes require a restart and are ignored")
+		next.TLS, next.CACertFile, next.CertFile, next.KeyFile = current.TLS, current.CACertFile, current.CertFile, current.KeyFile
+	}
+	if authorityChanged(current, next) {
+		log.Printf("enrollment: certificate authority changes require a restart and are ignored")
+		next.EnrollCACert, next.EnrollCAKey = current.EnrollCACert, current.EnrollCAKey
+	}
+	return next, nil
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/spf13/cobra"
+
+	"github.com/devodev/grpc-demo/internal/certs"
+)
+
+// EnrollConfig holds config for the enroll command.
+type EnrollConfig struct {
+	HubHTTPAddr        string `envconfig:"HUB_HTTP_URI" default:"https://localhost:8080"`
+	Token              string `envconfig:"ENROLLMENT_TOKEN"`
+	InsecureSkipVerify bool   `envconfig:"TLS_INSECURE_SKIP_VERIFY"`
+	CACertFile         string `envconfig:"TLS_CA_CERT_FILE"`
+	CertFile           string `envconfig:"TLS_CERT_FILE"`
+	KeyFile            string `envconfig:"TLS_KEY_FILE"`
+}
+
+func newCommandEnroll() *cobra.Command {
+	var c EnrollConfig
+	envconfig.Process("", &c)
+
+	cmd := &cobra.Command{
+		Use:   "enroll [name]",
+		Short: "request a client certificate from the hub certificate authority.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if c.CertFile == "" || c.KeyFile == "" {
+				return fmt.Errorf("--tls-cert-file and --tls-key-file are required")
+			}
+			u, err := url.Parse(c.HubHTTPAddr)
+			if err != nil {
+				return err
+			}
+			tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+			if c.CACertFile != "" {
+				reloader, err := certs.NewReloader("", "", c.CACertFile)
+				if err != nil {
+					return err
+				}
+				tlsConfig = reloader.ClientConfig(tlsConfig, u.Hostname())
+			}
+			certPEM, keyPEM, err := certs.Enroll(c.HubHTTPAddr, c.Token, name, tlsConfig)
+			if err != nil {
+				return fmt.Errorf("enrollment failed: %v", err)
+			}
+			if err := certs.WriteKeyPair(c.CertFile, c.KeyFile, certPEM, keyPEM); err != nil {
+				return err
+			}
+			writeOut(fmt.Sprintf("enrolled as %v: wrote %v and %v", name, c.CertFile, c.KeyFile))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&c.HubHTTPAddr, "hub-http-uri", c.HubHTTPAddr, "hub HTTP server uri")
+	cmd.Flags().StringVar(&c.Token, "token", c.Token, "single-use enrollment token")
+	cmd.Flags().BoolVar(&c.InsecureSkipVerify, "tls-insecure-skip-verify", c.InsecureSkipVerify, "INSECURE: skip tls checks")
+	cmd.Flags().StringVar(&c.CACertFile, "tls-ca-cert-file", c.CACertFile, "ca cert file used to verify the hub")
+	cmd.Flags().StringVar(&c.CertFile, "tls-cert-file", c.CertFile, "file the client certificate is written to")
+	cmd.Flags().StringVar(&c.KeyFile, "tls-key-file", c.KeyFile, "file the client key is written to")
+	return cmd
+}
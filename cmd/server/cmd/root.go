@@ -33,6 +33,7 @@ func newCommandRoot() *cobra.Command {
 	}
 	cmd.AddCommand(
 		newCommandServe(),
+		newCommandEnroll(),
 	)
 	return cmd
 }
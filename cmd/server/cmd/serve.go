@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 
 	api "github.com/devodev/grpc-demo/internal/api/remote"
 	"github.com/devodev/grpc-demo/internal/hub"
@@ -15,15 +20,48 @@ import (
 
 // Config holds config for the Fluentd command.
 type Config struct {
-	HubAddr            string `envconfig:"HUB_ADDR" default:"ws://localhost:8080/ws"`
-	InsecureSkipVerify bool   `envconfig:"TLS_INSECURE_SKIP_VERIFY"`
+	HubAddr            string            `envconfig:"HUB_ADDR" default:"ws://localhost:8080/ws"`
+	InsecureSkipVerify bool              `envconfig:"TLS_INSECURE_SKIP_VERIFY"`
+	CACertFile         string            `envconfig:"TLS_CA_CERT_FILE"`
+	CertFile           string            `envconfig:"TLS_CERT_FILE"`
+	KeyFile            string            `envconfig:"TLS_KEY_FILE"`
+	CertRenewalURI     string            `envconfig:"CERT_RENEWAL_URI"`
+	Labels             map[string]string `envconfig:"LABELS"`
+	WSReadLimit        int64             `envconfig:"WS_READ_LIMIT"`
+	WSMaxFrameSize     int               `envconfig:"WS_MAX_FRAME_SIZE"`
+	WSWriteCoalescing  bool              `envconfig:"WS_WRITE_COALESCING"`
+	WSCompression      bool              `envconfig:"WS_COMPRESSION"`
+	WSCompressionLevel int               `envconfig:"WS_COMPRESSION_LEVEL" default:"1"`
+	WSCompressionMin   int               `envconfig:"WS_COMPRESSION_THRESHOLD"`
+
+	SessionAcceptBacklog    int           `envconfig:"SESSION_ACCEPT_BACKLOG"`
+	SessionKeepAlive        time.Duration `envconfig:"SESSION_KEEPALIVE_INTERVAL"`
+	SessionDisableKeepAlive bool          `envconfig:"SESSION_DISABLE_KEEPALIVE"`
+	SessionWriteTimeout     time.Duration `envconfig:"SESSION_WRITE_TIMEOUT"`
+	SessionMaxStreamWindow  uint32        `envconfig:"SESSION_MAX_STREAM_WINDOW"`
 }
 
 // SetupCmd sets flags on the provided cmd and resolve env variables using the provided Config.
 func SetupCmd(cmd *cobra.Command, c *Config) *cobra.Command {
 	envconfig.Process("", c)
-	cmd.Flags().StringVar(&c.HubAddr, "hub-uri", c.HubAddr, "hub websocket uri.")
+	cmd.Flags().StringVar(&c.HubAddr, "hub-uri", c.HubAddr, "hub uri; the scheme selects the transport (ws, wss, tls or h2).")
 	cmd.Flags().BoolVar(&c.InsecureSkipVerify, "tls-insecure-skip-verify", c.InsecureSkipVerify, "INSECURE: skip tls checks")
+	cmd.Flags().StringVar(&c.CACertFile, "tls-ca-cert-file", c.CACertFile, "ca cert file used to verify the hub; reloaded when it changes")
+	cmd.Flags().StringVar(&c.CertFile, "tls-cert-file", c.CertFile, "client cert file presented to the hub; reloaded when it changes")
+	cmd.Flags().StringVar(&c.KeyFile, "tls-key-file", c.KeyFile, "client key file; reloaded when it changes")
+	cmd.Flags().StringVar(&c.CertRenewalURI, "cert-renewal-uri", c.CertRenewalURI, "hub HTTP server uri (ex.: https://localhost:8080) used to renew the client certificate of an enrolled agent before it expires")
+	cmd.Flags().Int64Var(&c.WSReadLimit, "ws-read-limit", c.WSReadLimit, "maximum size of a websocket message read from the hub; 0 for default (1MiB)")
+	cmd.Flags().IntVar(&c.WSMaxFrameSize, "ws-max-frame-size", c.WSMaxFrameSize, "maximum size of a websocket message written to the hub; 0 for default (32KiB)")
+	cmd.Flags().BoolVar(&c.WSWriteCoalescing, "ws-write-coalescing", c.WSWriteCoalescing, "coalesce small writes into fewer websocket messages")
+	cmd.Flags().BoolVar(&c.WSCompression, "ws-compression", c.WSCompression, "negotiate websocket per-message compression (permessage-deflate) with the hub")
+	cmd.Flags().IntVar(&c.WSCompressionLevel, "ws-compression-level", c.WSCompressionLevel, "websocket compression level, from -2 (huffman only) to 9 (best compression)")
+	cmd.Flags().IntVar(&c.WSCompressionMin, "ws-compression-threshold", c.WSCompressionMin, "minimum size of a compressed websocket message; 0 for default (256B)")
+	cmd.Flags().IntVar(&c.SessionAcceptBacklog, "session-accept-backlog", c.SessionAcceptBacklog, "maximum number of yamux streams waiting to be accepted; 0 for default (256)")
+	cmd.Flags().DurationVar(&c.SessionKeepAlive, "session-keepalive-interval", c.SessionKeepAlive, "yamux keep-alive interval; 0 for default (30s)")
+	cmd.Flags().BoolVar(&c.SessionDisableKeepAlive, "session-disable-keepalive", c.SessionDisableKeepAlive, "disable yamux keep-alives")
+	cmd.Flags().DurationVar(&c.SessionWriteTimeout, "session-write-timeout", c.SessionWriteTimeout, "yamux connection write timeout; 0 for default (10s)")
+	cmd.Flags().Uint32Var(&c.SessionMaxStreamWindow, "session-max-stream-window", c.SessionMaxStreamWindow, "maximum yamux stream window size in bytes; 0 for default (256KiB)")
+	cmd.Flags().StringToStringVar(&c.Labels, "label", c.Labels, "label reported to the hub in the form of key=value (repeatable)")
 	return cmd
 }
 
@@ -41,7 +79,38 @@ func newCommandServe() *cobra.Command {
 			// ? TODO: Could move validation inside Dial()
 			// ? TODO: The use case I see that might be useful is
 			// ? TODO: provide helper methods to set hub specific headers.
-			hubDialer, err := hub.NewConnector(config.HubAddr, config.InsecureSkipVerify, name)
+			opts := []hub.ConnectorOption{
+				hub.WithAgentVersion(cmd.Root().Version),
+				hub.WithLabels(config.Labels),
+				hub.WithConnectorWebsocketLimits(config.WSReadLimit, config.WSMaxFrameSize),
+				hub.WithConnectorWebsocketWriteCoalescing(config.WSWriteCoalescing),
+				hub.WithConnectorSessionConfig(hub.SessionConfig{
+					AcceptBacklog:          config.SessionAcceptBacklog,
+					KeepAliveInterval:      config.SessionKeepAlive,
+					DisableKeepAlive:       config.SessionDisableKeepAlive,
+					ConnectionWriteTimeout: config.SessionWriteTimeout,
+					MaxStreamWindowSize:    config.SessionMaxStreamWindow,
+				}),
+			}
+			if config.CACertFile != "" || config.CertFile != "" || config.KeyFile != "" {
+				opts = append(opts, hub.WithConnectorTLSFiles(config.CertFile, config.KeyFile, config.CACertFile))
+			}
+			if config.CertRenewalURI != "" {
+				opts = append(opts, hub.WithConnectorCertRenewal(config.CertRenewalURI))
+			}
+			if config.WSCompression {
+				opts = append(opts, hub.WithConnectorWebsocketCompression(config.WSCompressionLevel, config.WSCompressionMin))
+			}
+			// The hub announces its shutdown so that the server can
+			// register again, with another hub, while its calls drain.
+			goAway := make(chan string, 1)
+			opts = append(opts, hub.WithGoAwayHandler(func(message string) {
+				select {
+				case goAway <- message:
+				default:
+				}
+			}))
+			hubDialer, err := hub.NewConnector(config.HubAddr, config.InsecureSkipVerify, name, opts...)
 			if err != nil {
 				return err
 			}
@@ -56,23 +125,116 @@ func newCommandServe() *cobra.Command {
 			server := grpc.NewServer()
 			fluentdService := &api.FluentdService{}
 			fluentdService.RegisterServer(server)
+			// Lets callers discover the services, see client call.
+			reflection.Register(server)
 
-			go func() {
-				defer func() {
+			type served struct {
+				l   net.Listener
+				err error
+			}
+			done := make(chan served)
+			serve := func(l net.Listener) {
+				go func() { done <- served{l, server.Serve(l)} }()
+			}
+			serve(hubListener)
+
+			// The hub rejects a registration by closing the session
+			// it accepted: back off while the sessions are short-lived.
+			var backoff time.Duration
+			registered := time.Now()
+
+			// registerAgain returns false if interrupted.
+			registerAgain := func(reason string) bool {
+				if time.Since(registered) < reconnectMaxBackoff {
+					backoff = nextBackoff(backoff)
+				} else {
+					backoff = 0
+				}
+				log.Printf("%v, registering again", reason)
+				l, err := reconnect(hubDialer, backoff, interrupt)
+				if err != nil {
 					log.Println("graceful shutdown..")
 					server.GracefulStop()
-				}()
+					return false
+				}
+				registered = time.Now()
+				hubListener = l
+				serve(l)
+				return true
+			}
+
+			for {
 				select {
 				case <-interrupt:
+					log.Println("graceful shutdown..")
+					server.GracefulStop()
+					return nil
+				case message := <-goAway:
+					if !registerAgain(fmt.Sprintf("hub is going away (%v)", message)) {
+						return nil
+					}
+				case s := <-done:
+					if s.l != hubListener {
+						// The session of the previous hub is drained.
+						continue
+					}
+					if s.err == nil || s.err == grpc.ErrServerStopped {
+						return nil
+					}
+					reason := fmt.Sprintf("connection to the hub lost (%v)", s.err)
+					select {
+					case message := <-goAway:
+						// The hub closed the session right after announcing
+						// its shutdown, having no call to drain.
+						reason = fmt.Sprintf("hub is going away (%v)", message)
+					default:
+					}
+					// The hub keeps the name reserved for a while,
+					// see the hub --reconnect-grace-period.
+					if !registerAgain(reason) {
+						return nil
+					}
 				}
-			}()
-
-			if err := server.Serve(hubListener); err != nil && err != grpc.ErrServerStopped {
-				log.Fatal(err)
-				return err
 			}
-			return nil
 		},
 	}
 	return SetupCmd(cmd, &config)
 }
+
+var (
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = 30 * time.Second
+
+	errInterrupted = errors.New("interrupted")
+)
+
+// reconnect registers with the hub, after delay, until it succeeds or an
+// interrupt is received, in which case errInterrupted is returned.
+func reconnect(c *hub.Connector, delay time.Duration, interrupt <-chan os.Signal) (net.Listener, error) {
+	for {
+		if delay > 0 {
+			select {
+			case <-interrupt:
+				return nil, errInterrupted
+			case <-time.After(delay):
+			}
+		}
+		l, err := c.Listener()
+		if err == nil {
+			log.Println("registered again with the hub")
+			return l, nil
+		}
+		delay = nextBackoff(delay)
+		log.Printf("registering with the hub: %v; retrying in %v", err, delay)
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	if d *= 2; d < reconnectMinBackoff {
+		return reconnectMinBackoff
+	}
+	if d > reconnectMaxBackoff {
+		return reconnectMaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,140 @@
+package local
+
+import (
+	"net/http"
+	"strings"
+
+	pb "github.com/devodev/grpc-demo/internal/pb/local"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// GatewayPrefix is the path prefix under which the HTTP/JSON gateway
+// of the Hub service is expected to be mounted.
+const GatewayPrefix = "/api/v1/"
+
+// Handler returns an http.Handler exposing HubService as HTTP/JSON.
+//
+//	GET /api/v1/clients         ListClients
+//	GET /api/v1/clients/{name}  GetClient
+//	GET /api/v1/activity        StreamActivityFeed, as newline-delimited JSON
+//
+// Messages are encoded using the protobuf JSON mapping.
+func (s *HubService) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, GatewayPrefix), "/")
+		switch {
+		case path == "clients":
+			resp, err := s.ListClients(r.Context(), &pb.HubListClientsRequest{})
+			writeMessage(w, resp, err)
+		case strings.HasPrefix(path, "clients/"):
+			name := strings.TrimPrefix(path, "clients/")
+			resp, err := s.GetClient(r.Context(), &pb.HubGetClientRequest{Name: name})
+			writeMessage(w, resp, err)
+		case path == "activity":
+			s.streamActivityJSON(w, r)
+		default:
+			writeError(w, status.Errorf(codes.NotFound, "unknown path %v", r.URL.Path))
+		}
+	})
+}
+
+// streamActivityJSON writes ActivityEvent messages as they are received,
+// one JSON document per line. The stream ends when the client goes away,
+// so the HTTP server must not apply a write timeout to it.
+func (s *HubService) streamActivityJSON(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Errorf(codes.Unimplemented, "streaming not supported"))
+		return
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := s.ActivityFeed.GetCh(quit)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-ch:
+			if !ok {
+				return
+			}
+			b, err := protojson.Marshal(&pb.ActivityEvent{Message: message})
+			if err != nil {
+				return
+			}
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeMessage(w http.ResponseWriter, m proto.Message, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "marshal: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	b, merr := protojson.Marshal(protov1.MessageV2(st.Proto()))
+	if merr != nil {
+		http.Error(w, st.Message(), httpStatusFromCode(st.Code()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFromCode(st.Code()))
+	w.Write(append(b, '\n'))
+}
+
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	}
+	return http.StatusInternalServerError
+}
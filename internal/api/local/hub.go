@@ -13,10 +13,18 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// EnrollmentTokenIssuer creates single-use tokens allowing agents to enroll.
+type EnrollmentTokenIssuer interface {
+	CreateEnrollmentToken(name string, ttl time.Duration) (string, time.Time, error)
+}
+
 // HubService implements pb.Hub.
 type HubService struct {
 	Registry     client.Registry
 	ActivityFeed *feed.Feed
+
+	// Enrollment is nil unless the hub certificate authority is enabled.
+	Enrollment EnrollmentTokenIssuer
 }
 
 // RegisterServer resgisters itself to a grpc server.
@@ -32,17 +40,23 @@ func (s *HubService) ListClients(ctx context.Context, r *pb.HubListClientsReques
 
 	var clients []*pb.Client
 	for _, client := range clientList {
-		cclient := client
-		c := &pb.Client{
-			Name:           cclient.Name,
-			ConnectionTime: cclient.ConnectionTime.String(),
-			Uptime:         now.Sub(cclient.ConnectionTime).String(),
-		}
-		clients = append(clients, c)
+		clients = append(clients, toPBClient(client, now))
 	}
 	return &pb.HubListClientsResponse{Count: int64(len(clients)), Clients: clients}, nil
 }
 
+// GetClient returns the connection and traffic details of a single client.
+func (s *HubService) GetClient(ctx context.Context, r *pb.HubGetClientRequest) (*pb.HubGetClientResponse, error) {
+	if r.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "name is empty")
+	}
+	client, err := s.Registry.Get(r.GetName())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "client %v not found", r.GetName())
+	}
+	return &pb.HubGetClientResponse{Client: toPBClient(client, time.Now())}, nil
+}
+
 // StreamActivityFeed returns a stream of ActivityEvent.
 func (s *HubService) StreamActivityFeed(req *pb.HubActivityFeedRequest, server pb.Hub_StreamActivityFeedServer) error {
 	quit := make(chan struct{})
@@ -56,3 +70,53 @@ func (s *HubService) StreamActivityFeed(req *pb.HubActivityFeedRequest, server p
 	}
 	return nil
 }
+
+// CreateEnrollmentToken returns a single-use token allowing an agent to enroll.
+func (s *HubService) CreateEnrollmentToken(ctx context.Context, r *pb.HubCreateEnrollmentTokenRequest) (*pb.HubCreateEnrollmentTokenResponse, error) {
+	if s.Enrollment == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "certificate authority is not enabled")
+	}
+	if r.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "name is empty")
+	}
+	var ttl time.Duration
+	if r.GetTtl() != "" {
+		d, err := time.ParseDuration(r.GetTtl())
+		if err != nil || d <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid ttl: %q", r.GetTtl())
+		}
+		ttl = d
+	}
+	token, expires, err := s.Enrollment.CreateEnrollmentToken(r.GetName(), ttl)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "create enrollment token: %v", err)
+	}
+	return &pb.HubCreateEnrollmentTokenResponse{
+		Token:          token,
+		Name:           r.GetName(),
+		ExpirationTime: expires.String(),
+	}, nil
+}
+
+func toPBClient(c *client.Client, now time.Time) *pb.Client {
+	return &pb.Client{
+		Name:              c.Name,
+		Type:              c.Type,
+		ConnectionTime:    c.ConnectionTime.String(),
+		Uptime:            now.Sub(c.ConnectionTime).String(),
+		RemoteAddr:        c.RemoteAddr,
+		UserAgent:         c.UserAgent,
+		TlsVersion:        c.TLSVersion,
+		TlsPeerSubject:    c.TLSPeerSubject,
+		AgentVersion:      c.AgentVersion,
+		OpenStreams:       int64(c.OpenStreams()),
+		BytesSent:         c.Stats.BytesSent(),
+		BytesReceived:     c.Stats.BytesReceived(),
+		ProxiedCalls:      c.Stats.ProxiedCalls(),
+		LastActivity:      c.Stats.LastActivity().String(),
+		Labels:            c.Labels,
+		WireBytesSent:     c.Stats.WireBytesSent(),
+		WireBytesReceived: c.Stats.WireBytesReceived(),
+		CompressionRatio:  c.Stats.CompressionRatio(),
+	}
+}
@@ -0,0 +1,320 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var defaultCheckInterval = 10 * time.Second
+
+// Option provide a way to configure a Reloader.
+type Option func(*Reloader)
+
+// WithCheckInterval sets the minimum interval between two checks
+// of the files for changes.
+func WithCheckInterval(d time.Duration) Option {
+	return func(r *Reloader) {
+		r.interval = d
+	}
+}
+
+// WithReloadHandler sets a function called after every reload attempt
+// with its result. On failure, the previously loaded files stay in use.
+func WithReloadHandler(f func(error)) Option {
+	return func(r *Reloader) {
+		r.onReload = f
+	}
+}
+
+// Reloader provides a certificate key pair and a CA bundle loaded from
+// files, reloading them when the files change so that rotated
+// certificates are used without restarting.
+//
+// The files are checked during TLS handshakes, at most once per check
+// interval, so that established connections are not affected.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	interval time.Duration
+	onReload func(error)
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	stamps    map[string]fileStamp
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the provided files. The key pair and the CA bundle
+// are both optional, but the cert and key files go together.
+func NewReloader(certFile, keyFile, caFile string, opts ...Option) (*Reloader, error) {
+	if certFile == "" && keyFile != "" {
+		return nil, fmt.Errorf("missing cert file")
+	}
+	if certFile != "" && keyFile == "" {
+		return nil, fmt.Errorf("missing key file")
+	}
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: defaultCheckInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// load reads the files. It must be called with the lock held,
+// or before the Reloader is shared.
+func (r *Reloader) load() error {
+	stamps := make(map[string]fileStamp)
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		stamps[f] = fileStamp{fi.ModTime(), fi.Size()}
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("cert/key: %v", err)
+		}
+		cert = &pair
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		cacert, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("ca cert: %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cacert) {
+			return fmt.Errorf("ca cert: no certificate found in %v", r.caFile)
+		}
+	}
+	r.stamps = stamps
+	r.cert = cert
+	r.pool = pool
+	return nil
+}
+
+// changed reports whether any file changed since the last load.
+func (r *Reloader) changed() bool {
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// Files being replaced may be missing for a moment.
+			continue
+		}
+		if (fileStamp{fi.ModTime(), fi.Size()}) != r.stamps[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// check reloads the files if they changed and the check interval elapsed.
+func (r *Reloader) check() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	if !r.changed() {
+		r.mu.Unlock()
+		return
+	}
+	err := r.load()
+	if err != nil {
+		// Retry on the next check even if the files do not change again.
+		r.stamps = nil
+	}
+	onReload := r.onReload
+	r.mu.Unlock()
+
+	if onReload != nil {
+		onReload(err)
+	}
+}
+
+// Reload loads the files immediately, for instance after replacing them.
+// On failure, the previously loaded files stay in use.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+	err := r.load()
+	if err != nil {
+		r.stamps = nil
+	}
+	return err
+}
+
+// Certificate returns the current key pair, or nil if none is configured.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.check()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+// CertPool returns the current CA bundle, or nil if none is configured.
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.check()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pool
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := r.Certificate()
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate configured")
+	}
+	return cert, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate.
+// No certificate is sent if none is configured.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := r.Certificate(); cert != nil {
+		return cert, nil
+	}
+	return &tls.Certificate{}, nil
+}
+
+// ServerConfig returns a copy of base serving the current key pair.
+//
+// When a CA bundle is configured, the certificates presented by clients
+// are verified using the current bundle, so that a rotated CA is used by
+// new connections. Clients presenting none are accepted unless base
+// requires a client certificate.
+func (r *Reloader) ServerConfig(base *tls.Config) *tls.Config {
+	c := clone(base)
+	if r.certFile != "" {
+		c.Certificates = nil
+		c.GetCertificate = r.GetCertificate
+	}
+	if r.caFile != "" {
+		// The default verification uses a fixed ClientCAs pool,
+		// so it is replaced by one using the current pool.
+		switch c.ClientAuth {
+		case tls.RequireAnyClientCert, tls.RequireAndVerifyClientCert:
+			c.ClientAuth = tls.RequireAnyClientCert
+		default:
+			c.ClientAuth = tls.RequestClientCert
+		}
+		c.ClientCAs = nil
+		c.VerifyPeerCertificate = r.clientVerifier()
+	}
+	return c
+}
+
+// ClientConfig returns a copy of base presenting the current key pair
+// and verifying the certificate of serverName using the current CA bundle.
+func (r *Reloader) ClientConfig(base *tls.Config, serverName string) *tls.Config {
+	c := clone(base)
+	if r.certFile != "" {
+		c.Certificates = nil
+		c.GetClientCertificate = r.GetClientCertificate
+	}
+	if r.caFile != "" && !c.InsecureSkipVerify {
+		// The default verification uses a fixed RootCAs pool,
+		// so it is replaced by one using the current pool.
+		c.InsecureSkipVerify = true
+		c.RootCAs = nil
+		c.VerifyPeerCertificate = r.verifier(serverName)
+	}
+	return c
+}
+
+func (r *Reloader) verifier(serverName string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no server certificate")
+		}
+		return r.verify(rawCerts, x509.VerifyOptions{DNSName: serverName})
+	}
+}
+
+func (r *Reloader) clientVerifier() func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			// Required certificates are enforced by the ClientAuth policy.
+			return nil
+		}
+		return r.verify(rawCerts, x509.VerifyOptions{
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+	}
+}
+
+// verify verifies the certificate chain rawCerts using the current CA bundle.
+func (r *Reloader) verify(rawCerts [][]byte, opts x509.VerifyOptions) error {
+	certs, err := ParseChain(rawCerts)
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts.Roots = r.CertPool()
+	opts.Intermediates = intermediates
+	_, err = certs[0].Verify(opts)
+	return err
+}
+
+// ParseChain parses the DER certificates of a chain, such as the
+// ones passed to tls.Config.VerifyPeerCertificate.
+func ParseChain(rawCerts [][]byte) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		certs[i] = cert
+	}
+	return certs, nil
+}
+
+func clone(c *tls.Config) *tls.Config {
+	if c == nil {
+		return &tls.Config{}
+	}
+	return c.Clone()
+}
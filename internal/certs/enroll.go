@@ -0,0 +1,204 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Paths of the enrollment endpoints on the hub HTTP server.
+const (
+	EnrollPath = "/enroll"
+	RenewPath  = "/enroll/renew"
+)
+
+var (
+	defaultEnrollTimeout = 30 * time.Second
+	defaultRenewRetry    = time.Minute
+	minRenewWait         = 10 * time.Second
+)
+
+// EnrollRequest is the body of the requests sent to the enrollment
+// endpoints. The token is only used by EnrollPath, RenewPath relying
+// on the current client certificate instead.
+type EnrollRequest struct {
+	Token string `json:"token,omitempty"`
+	CSR   string `json:"csr"`
+}
+
+// EnrollResponse is the body of the responses of the enrollment endpoints.
+type EnrollResponse struct {
+	Certificate string `json:"certificate"`
+}
+
+// Enroll requests a client certificate for name from the hub at uri,
+// using a single-use enrollment token. It returns the PEM encoded
+// certificate and private key.
+func Enroll(uri, token, name string, tlsConfig *tls.Config) (certPEM, keyPEM []byte, err error) {
+	if token == "" {
+		return nil, nil, fmt.Errorf("enrollment token is empty")
+	}
+	return requestCertificate(uri, EnrollPath, token, name, tlsConfig)
+}
+
+func requestCertificate(uri, path, token, name string, tlsConfig *tls.Config) (certPEM, keyPEM []byte, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, nil, fmt.Errorf("enrollment requires an https uri: %v", uri)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+
+	csrPEM, keyPEM, err := NewCertificateRequest(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(&EnrollRequest{Token: token, CSR: string(csrPEM)})
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{
+		Timeout:   defaultEnrollTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(b)))
+	}
+	var enrollResp EnrollResponse
+	if err := json.Unmarshal(b, &enrollResp); err != nil {
+		return nil, nil, err
+	}
+	return []byte(enrollResp.Certificate), keyPEM, nil
+}
+
+// WriteKeyPair writes the PEM encoded certificate and private key
+// to their files, replacing them atomically.
+func WriteKeyPair(certFile, keyFile string, certPEM, keyPEM []byte) error {
+	if err := writeFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	return writeFile(certFile, certPEM, 0644)
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Renewer renews the client certificate of a Reloader through the
+// enrollment endpoint of a hub before it expires, replacing its files.
+type Renewer struct {
+	Reloader *Reloader
+
+	// URI is the https uri of the hub HTTP server.
+	URI string
+
+	// TLSConfig is used to verify the hub; the client certificate
+	// is provided by the Reloader.
+	TLSConfig *tls.Config
+
+	// OnRenew, if set, is called after every renewal attempt with its result.
+	OnRenew func(error)
+}
+
+// Run renews the certificate once two thirds of its lifetime have elapsed,
+// retrying on failure, until stop is closed.
+func (r *Renewer) Run(stop <-chan struct{}) {
+	for {
+		wait := defaultRenewRetry
+		if leaf, err := r.leaf(); err == nil {
+			lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+			wait = time.Until(leaf.NotBefore.Add(lifetime * 2 / 3))
+		}
+		if wait < minRenewWait {
+			// Do not renew in a loop certificates that cannot be extended,
+			// such as the ones capped by the expiry of the CA.
+			wait = minRenewWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := r.Renew()
+		if r.OnRenew != nil {
+			r.OnRenew(err)
+		}
+		if err != nil {
+			// Do not retry immediately on a certificate due for renewal.
+			select {
+			case <-stop:
+				return
+			case <-time.After(defaultRenewRetry):
+			}
+		}
+	}
+}
+
+// Renew requests a new certificate for the name of the current one
+// and replaces the files of the Reloader.
+func (r *Renewer) Renew() error {
+	leaf, err := r.leaf()
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(r.URI)
+	if err != nil {
+		return err
+	}
+	tlsConfig := r.Reloader.ClientConfig(r.TLSConfig, u.Hostname())
+	certPEM, keyPEM, err := requestCertificate(r.URI, RenewPath, "", leaf.Subject.CommonName, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("certificate renewal: %v", err)
+	}
+	if err := WriteKeyPair(r.Reloader.certFile, r.Reloader.keyFile, certPEM, keyPEM); err != nil {
+		return fmt.Errorf("certificate renewal: %v", err)
+	}
+	return r.Reloader.Reload()
+}
+
+func (r *Renewer) leaf() (*x509.Certificate, error) {
+	cert := r.Reloader.Certificate()
+	if cert == nil {
+		return nil, fmt.Errorf("no client certificate configured")
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
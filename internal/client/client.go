@@ -1,11 +1,13 @@
 package client
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"time"
 
 	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
 )
 
 // ErrEmptyAttribute .
@@ -17,23 +19,169 @@ func (e ErrEmptyAttribute) Error() string {
 	return fmt.Sprintf("%v is empty", e.attName)
 }
 
+// Option provide a way to configure a Client.
+type Option func(*Client)
+
+// WithRemoteAddr sets the address the client connected from.
+func WithRemoteAddr(a string) Option {
+	return func(c *Client) {
+		c.RemoteAddr = a
+	}
+}
+
+// WithUserAgent sets the user-agent the client connected with.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.UserAgent = ua
+	}
+}
+
+// WithAgentVersion sets the version reported by the client.
+func WithAgentVersion(v string) Option {
+	return func(c *Client) {
+		c.AgentVersion = v
+	}
+}
+
+// WithLabels sets the labels reported by the client.
+func WithLabels(l map[string]string) Option {
+	return func(c *Client) {
+		c.Labels = l
+	}
+}
+
+// WithTLSConnectionState sets the TLS details of the client connection.
+// A nil state is ignored.
+func WithTLSConnectionState(s *tls.ConnectionState) Option {
+	return func(c *Client) {
+		if s == nil {
+			return
+		}
+		c.TLSVersion = tlsVersionName(s.Version)
+		if len(s.PeerCertificates) > 0 {
+			c.TLSPeerSubject = s.PeerCertificates[0].Subject.String()
+		}
+	}
+}
+
+// WithStats sets the Stats recording the client traffic,
+// typically to share it with a connection metered using Stats.MeterConn.
+// A nil value is ignored.
+func WithStats(s *Stats) Option {
+	return func(c *Client) {
+		if s != nil {
+			c.Stats = s
+		}
+	}
+}
+
+// WithSessionConfig sets the configuration of the yamux session
+// wrapping the client connection. A nil value keeps the defaults.
+func WithSessionConfig(cfg *yamux.Config) Option {
+	return func(c *Client) {
+		c.sessionConfig = cfg
+	}
+}
+
+// Types of clients.
+const (
+	// TypeAgent clients dial the hub and are reached through their session.
+	TypeAgent = "agent"
+
+	// TypeUpstream clients are gRPC servers dialed by the hub.
+	TypeUpstream = "upstream"
+)
+
 // Client represents a remote gRPC server.
-// The session stored wraps a RWC.
+//
+// Agents are reached through a session wrapping a RWC,
+// upstreams through a gRPC connection.
 type Client struct {
 	Name           string
+	Type           string
 	ConnectionTime time.Time
 
+	RemoteAddr     string
+	UserAgent      string
+	AgentVersion   string
+	TLSVersion     string
+	TLSPeerSubject string
+	Labels         map[string]string
+
+	Stats   *Stats
 	Session *yamux.Session
+
+	// Conn is nil unless the client is an upstream.
+	Conn *grpc.ClientConn
+
+	sessionConfig *yamux.Config
 }
 
 // New creates a client using the provided ReadWriteCloser and name.
-func New(rwc io.ReadWriteCloser, name string) (*Client, error) {
+// The ReadWriteCloser is wrapped so that traffic is recorded in Stats.
+func New(rwc io.ReadWriteCloser, name string, opts ...Option) (*Client, error) {
 	if name == "" {
 		return nil, &ErrEmptyAttribute{"name"}
 	}
-	s, err := yamux.Client(rwc, yamux.DefaultConfig())
+	c := &Client{Name: name, Type: TypeAgent, ConnectionTime: time.Now(), Stats: &Stats{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Stats.touch()
+	s, err := yamux.Client(&meteredRWC{rwc, c.Stats}, c.sessionConfig)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{Name: name, ConnectionTime: time.Now(), Session: s}, nil
+	c.Session = s
+	return c, nil
+}
+
+// NewUpstream creates a client for the gRPC server at address, reached
+// through conn. Use Stats.Meter when dialing conn to record its traffic.
+func NewUpstream(name, address string, conn *grpc.ClientConn, opts ...Option) (*Client, error) {
+	if name == "" {
+		return nil, &ErrEmptyAttribute{"name"}
+	}
+	if address == "" {
+		return nil, &ErrEmptyAttribute{"address"}
+	}
+	c := &Client{Name: name, Type: TypeUpstream, ConnectionTime: time.Now(), RemoteAddr: address, Stats: &Stats{}, Conn: conn}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Stats.touch()
+	return c, nil
+}
+
+// IsClosed reports whether the session of an agent is closed.
+// Upstreams are never closed, their connection being re-established
+// as needed.
+func (c *Client) IsClosed() bool {
+	if c.Session == nil {
+		return false
+	}
+	return c.Session.IsClosed()
+}
+
+// OpenStreams returns the number of streams open on the session
+// of an agent, or zero for an upstream.
+func (c *Client) OpenStreams() int {
+	if c.Session == nil {
+		return 0
+	}
+	return c.Session.NumStreams()
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
 }
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the traffic counters of a client.
+// It is safe for concurrent use.
+type Stats struct {
+	bytesSent     int64
+	bytesReceived int64
+	proxiedCalls  int64
+	lastActivity  int64
+
+	wireBytesSent     int64
+	wireBytesReceived int64
+}
+
+// BytesSent returns the number of bytes written to the client.
+func (s *Stats) BytesSent() int64 {
+	return atomic.LoadInt64(&s.bytesSent)
+}
+
+// BytesReceived returns the number of bytes read from the client.
+func (s *Stats) BytesReceived() int64 {
+	return atomic.LoadInt64(&s.bytesReceived)
+}
+
+// WireBytesSent returns the number of bytes written to the client
+// connection, after websocket framing and compression.
+// It is zero if the connection is not metered, see MeterConn.
+func (s *Stats) WireBytesSent() int64 {
+	return atomic.LoadInt64(&s.wireBytesSent)
+}
+
+// WireBytesReceived returns the number of bytes read from the client
+// connection, before websocket framing and decompression.
+// It is zero if the connection is not metered, see MeterConn.
+func (s *Stats) WireBytesReceived() int64 {
+	return atomic.LoadInt64(&s.wireBytesReceived)
+}
+
+// CompressionRatio returns the ratio of the bytes exchanged with the client
+// over the bytes exchanged on the wire, or zero if the connection
+// is not metered.
+func (s *Stats) CompressionRatio() float64 {
+	wire := s.WireBytesSent() + s.WireBytesReceived()
+	if wire == 0 {
+		return 0
+	}
+	return float64(s.BytesSent()+s.BytesReceived()) / float64(wire)
+}
+
+// MeterConn wraps c so that the bytes going through it
+// are recorded as wire bytes.
+func (s *Stats) MeterConn(c net.Conn) net.Conn {
+	return &meteredConn{c, s}
+}
+
+// Meter wraps c so that the bytes going through it are recorded as the
+// bytes exchanged with the client, for clients reached without a session.
+func (s *Stats) Meter(c net.Conn) net.Conn {
+	return &trafficConn{c, &meteredRWC{c, s}}
+}
+
+// ProxiedCalls returns the number of gRPC calls proxied to the client.
+func (s *Stats) ProxiedCalls() int64 {
+	return atomic.LoadInt64(&s.proxiedCalls)
+}
+
+// LastActivity returns the last time data was exchanged with the client
+// or a call was proxied to it.
+func (s *Stats) LastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActivity))
+}
+
+// IncProxiedCalls increments the proxied calls counter.
+func (s *Stats) IncProxiedCalls() {
+	atomic.AddInt64(&s.proxiedCalls, 1)
+	s.touch()
+}
+
+func (s *Stats) touch() {
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+}
+
+// meteredRWC wraps a ReadWriteCloser and records
+// the traffic going through it.
+type meteredRWC struct {
+	io.ReadWriteCloser
+	stats *Stats
+}
+
+func (m *meteredRWC) Read(p []byte) (int, error) {
+	n, err := m.ReadWriteCloser.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&m.stats.bytesReceived, int64(n))
+		m.stats.touch()
+	}
+	return n, err
+}
+
+func (m *meteredRWC) Write(p []byte) (int, error) {
+	n, err := m.ReadWriteCloser.Write(p)
+	if n > 0 {
+		atomic.AddInt64(&m.stats.bytesSent, int64(n))
+		m.stats.touch()
+	}
+	return n, err
+}
+
+// meteredConn wraps a net.Conn and records the bytes going through it.
+type meteredConn struct {
+	net.Conn
+	stats *Stats
+}
+
+func (m *meteredConn) Read(p []byte) (int, error) {
+	n, err := m.Conn.Read(p)
+	atomic.AddInt64(&m.stats.wireBytesReceived, int64(n))
+	return n, err
+}
+
+func (m *meteredConn) Write(p []byte) (int, error) {
+	n, err := m.Conn.Write(p)
+	atomic.AddInt64(&m.stats.wireBytesSent, int64(n))
+	return n, err
+}
+
+// trafficConn wraps a net.Conn and records the traffic going through it.
+type trafficConn struct {
+	net.Conn
+	rwc *meteredRWC
+}
+
+func (t *trafficConn) Read(p []byte) (int, error) {
+	return t.rwc.Read(p)
+}
+
+func (t *trafficConn) Write(p []byte) (int, error) {
+	return t.rwc.Write(p)
+}
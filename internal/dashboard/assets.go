@@ -0,0 +1,281 @@
+package dashboard
+
+const indexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>gRPC Hub</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<header>
+	<h1><a href="#/">gRPC Hub</a></h1>
+	<span id="status" class="status">connecting..</span>
+</header>
+<main>
+	<section id="view"></section>
+	<section>
+		<h2>Activity</h2>
+		<ul id="activity" class="activity"></ul>
+	</section>
+</main>
+<script src="app.js"></script>
+</body>
+</html>
+`
+
+const styleCSS = `body {
+	font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif;
+	margin: 0;
+	color: #222;
+	background: #f6f7f9;
+}
+header {
+	display: flex;
+	align-items: center;
+	justify-content: space-between;
+	padding: 0 1.5rem;
+	background: #24292e;
+}
+header h1 a {
+	color: #fff;
+	font-size: 1.25rem;
+	text-decoration: none;
+}
+main {
+	padding: 1rem 1.5rem;
+}
+table {
+	border-collapse: collapse;
+	width: 100%;
+	background: #fff;
+}
+th, td {
+	text-align: left;
+	padding: .4rem .6rem;
+	border-bottom: 1px solid #e1e4e8;
+}
+.status {
+	color: #ccc;
+	font-size: .85rem;
+}
+.status.ok {
+	color: #85e89d;
+}
+.label {
+	display: inline-block;
+	margin: 0 .25rem .1rem 0;
+	padding: 0 .4rem;
+	border-radius: .6rem;
+	background: #e1e4e8;
+	font-size: .8rem;
+}
+.activity {
+	list-style: none;
+	padding: 0;
+	max-height: 20rem;
+	overflow-y: auto;
+	font-family: monospace;
+	background: #fff;
+}
+.activity li {
+	padding: .2rem .6rem;
+	border-bottom: 1px solid #f0f0f0;
+}
+.error {
+	color: #b31d28;
+}
+`
+
+const appJS = `(function () {
+	"use strict";
+
+	var api = "/api/v1/";
+	var events = "events";
+	var maxActivity = 200;
+
+	function token() {
+		return sessionStorage.getItem("hubToken");
+	}
+
+	function headers() {
+		var h = {};
+		if (token()) {
+			h["Authorization"] = "Bearer " + token();
+		}
+		return h;
+	}
+
+	function request(url) {
+		return fetch(url, {headers: headers()}).then(function (resp) {
+			if (resp.status === 401) {
+				var t = prompt("Hub auth token");
+				if (t) {
+					sessionStorage.setItem("hubToken", t);
+					return request(url);
+				}
+			}
+			return resp;
+		});
+	}
+
+	function el(tag, text, cls) {
+		var e = document.createElement(tag);
+		if (text !== undefined) {
+			e.textContent = text;
+		}
+		if (cls) {
+			e.className = cls;
+		}
+		return e;
+	}
+
+	function labels(l) {
+		var span = el("span");
+		Object.keys(l || {}).sort().forEach(function (k) {
+			span.appendChild(el("span", k + "=" + l[k], "label"));
+		});
+		return span;
+	}
+
+	function showError(view, message) {
+		view.textContent = "";
+		view.appendChild(el("p", message, "error"));
+	}
+
+	function renderList(view) {
+		request(api + "clients").then(function (resp) {
+			return resp.json().then(function (body) {
+				if (!resp.ok) {
+					throw new Error(body.message || resp.statusText);
+				}
+				return body;
+			});
+		}).then(function (body) {
+			var clients = (body.clients || []).sort(function (a, b) {
+				return a.name.localeCompare(b.name);
+			});
+			var table = el("table");
+			var head = el("tr");
+			["Name", "Type", "Uptime", "Agent version", "Labels"].forEach(function (h) {
+				head.appendChild(el("th", h));
+			});
+			table.appendChild(head);
+			clients.forEach(function (c) {
+				var row = el("tr");
+				var link = el("a", c.name);
+				link.href = "#/clients/" + encodeURIComponent(c.name);
+				row.appendChild(el("td")).appendChild(link);
+				row.appendChild(el("td", c.type || "agent"));
+				row.appendChild(el("td", c.uptime));
+				row.appendChild(el("td", c.agentVersion || ""));
+				row.appendChild(el("td")).appendChild(labels(c.labels));
+				table.appendChild(row);
+			});
+			view.textContent = "";
+			view.appendChild(el("h2", "Clients (" + clients.length + ")"));
+			view.appendChild(table);
+		}).catch(function (err) {
+			showError(view, err.message);
+		});
+	}
+
+	function renderClient(view, name) {
+		request(api + "clients/" + encodeURIComponent(name)).then(function (resp) {
+			return resp.json().then(function (body) {
+				if (!resp.ok) {
+					throw new Error(body.message || resp.statusText);
+				}
+				return body;
+			});
+		}).then(function (body) {
+			var c = body.client || {};
+			var table = el("table");
+			Object.keys(c).forEach(function (k) {
+				var row = el("tr");
+				row.appendChild(el("th", k));
+				if (k === "labels") {
+					row.appendChild(el("td")).appendChild(labels(c[k]));
+				} else {
+					row.appendChild(el("td", String(c[k])));
+				}
+				table.appendChild(row);
+			});
+			view.textContent = "";
+			view.appendChild(el("h2", c.name));
+			view.appendChild(table);
+		}).catch(function (err) {
+			showError(view, err.message);
+		});
+	}
+
+	function render() {
+		var view = document.getElementById("view");
+		var m = location.hash.match(/^#\/clients\/(.+)$/);
+		if (m) {
+			renderClient(view, decodeURIComponent(m[1]));
+		} else {
+			renderList(view);
+		}
+	}
+
+	function addActivity(message) {
+		var list = document.getElementById("activity");
+		var item = el("li", new Date().toLocaleTimeString() + "  " + message);
+		list.insertBefore(item, list.firstChild);
+		while (list.childNodes.length > maxActivity) {
+			list.removeChild(list.lastChild);
+		}
+		render();
+	}
+
+	function setStatus(ok) {
+		var s = document.getElementById("status");
+		s.textContent = ok ? "live" : "reconnecting..";
+		s.className = ok ? "status ok" : "status";
+	}
+
+	// streamEvents reads the server-sent events stream using fetch
+	// so that the Authorization header can be provided.
+	function streamEvents() {
+		request(events).then(function (resp) {
+			if (!resp.ok || !resp.body) {
+				throw new Error(resp.statusText);
+			}
+			setStatus(true);
+			var reader = resp.body.getReader();
+			var decoder = new TextDecoder();
+			var buf = "";
+			function read() {
+				return reader.read().then(function (r) {
+					if (r.done) {
+						return;
+					}
+					buf += decoder.decode(r.value, {stream: true});
+					var parts = buf.split("\n\n");
+					buf = parts.pop();
+					parts.forEach(function (part) {
+						part.split("\n").forEach(function (line) {
+							if (line.indexOf("data: ") === 0) {
+								addActivity(line.slice(6));
+							}
+						});
+					});
+					return read();
+				});
+			}
+			return read();
+		}).catch(function () {
+		}).then(function () {
+			setStatus(false);
+			setTimeout(streamEvents, 1000);
+		});
+	}
+
+	window.addEventListener("hashchange", render);
+	render();
+	setInterval(render, 5000);
+	streamEvents();
+})();
+`
@@ -0,0 +1,108 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/feed"
+)
+
+// Prefix is the path prefix under which the dashboard
+// is expected to be mounted.
+const Prefix = "/dashboard/"
+
+// EventsPath is the path of the server-sent events endpoint.
+const EventsPath = Prefix + "events"
+
+var keepAlivePeriod = 15 * time.Second
+
+type asset struct {
+	contentType string
+	content     string
+}
+
+// assets are compiled into the binary so that the hub
+// can be shipped as a single file.
+var assets = map[string]asset{
+	"":           {"text/html; charset=utf-8", indexHTML},
+	"index.html": {"text/html; charset=utf-8", indexHTML},
+	"app.js":     {"application/javascript; charset=utf-8", appJS},
+	"style.css":  {"text/css; charset=utf-8", styleCSS},
+}
+
+var startTime = time.Now()
+
+// Handler serves the static assets of the dashboard.
+//
+// The dashboard reads client information from the Hub HTTP/JSON API
+// and the activity feed from the events endpoint.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		a, ok := assets[strings.TrimPrefix(r.URL.Path, Prefix)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, "", startTime, strings.NewReader(a.content))
+	})
+}
+
+// EventsHandler streams the messages of the provided feed
+// as server-sent events.
+//
+// The stream ends when the client goes away, so the HTTP server must not
+// apply a write timeout to it. The dashboard reconnects when it ends.
+func EventsHandler(f *feed.Feed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusNotImplemented)
+			return
+		}
+		quit := make(chan struct{})
+		defer close(quit)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		ticker := time.NewTicker(keepAlivePeriod)
+		defer ticker.Stop()
+
+		ch := f.GetCh(quit)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case message, ok := <-ch:
+				if !ok {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "event: activity\ndata: %s\n\n", sanitize(message)); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// sanitize keeps a message on a single data line.
+func sanitize(m string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(m)
+}
@@ -10,7 +10,7 @@ type Feed struct {
 	ch chan string
 
 	mu      *sync.Mutex
-	readers map[chan string]struct{}
+	readers map[chan string]chan struct{}
 }
 
 // New .
@@ -19,7 +19,7 @@ func New() *Feed {
 		ch: make(chan string),
 
 		mu:      &sync.Mutex{},
-		readers: make(map[chan string]struct{}),
+		readers: make(map[chan string]chan struct{}),
 	}
 }
 
@@ -33,18 +33,20 @@ func (f *Feed) Send(message string) error {
 	return nil
 }
 
-// GetCh .
+// GetCh returns a channel receiving every message sent to the feed
+// until quit is closed. Readers that stop receiving must close quit
+// so that the router does not wait on them.
 func (f *Feed) GetCh(quit chan struct{}) <-chan string {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	ch := make(chan string)
-	f.readers[ch] = struct{}{}
+	f.readers[ch] = quit
 
 	go func() {
-		defer delete(f.readers, ch)
-		select {
-		case <-quit:
-		}
+		<-quit
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.readers, ch)
 	}()
 
 	return ch
@@ -61,17 +63,23 @@ Loop:
 		case message := <-f.ch:
 			f.mu.Lock()
 			wg.Add(len(f.readers))
-			for readerCh := range f.readers {
-				go func(ch chan string) {
+			for readerCh, readerQuit := range f.readers {
+				go func(ch chan string, q chan struct{}) {
 					defer wg.Done()
-					ch <- message
-				}(readerCh)
+					select {
+					case ch <- message:
+					case <-q:
+					}
+				}(readerCh, readerQuit)
 			}
 			wg.Wait()
 			f.mu.Unlock()
 		}
 	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	for ch := range f.readers {
 		close(ch)
+		delete(f.readers, ch)
 	}
 }
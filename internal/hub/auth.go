@@ -0,0 +1,89 @@
+package hub
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthFunc validates the authorization value provided by a caller,
+// either through the "authorization" gRPC metadata key
+// or the Authorization HTTP header.
+type AuthFunc func(authorization string) error
+
+// TokenAuth returns an AuthFunc that accepts any of the provided
+// tokens when sent using the Bearer scheme.
+func TokenAuth(tokens ...string) AuthFunc {
+	return func(authorization string) error {
+		parts := strings.SplitN(authorization, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			return fmt.Errorf("bearer token not found")
+		}
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(t)) == 1 {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid token")
+	}
+}
+
+// WithAuthFunc sets the function used to authenticate callers
+// of the gRPC server and the HTTP API.
+func WithAuthFunc(f AuthFunc) Option {
+	return func(h *Hub) error {
+		h.settings.authFunc = f
+		return nil
+	}
+}
+
+func (h *Hub) authenticate(ctx context.Context) error {
+	authFunc := h.currentSettings().authFunc
+	if authFunc == nil {
+		return nil
+	}
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	if err := authFunc(authorization); err != nil {
+		return status.Errorf(codes.Unauthenticated, "authentication failed: %v", err)
+	}
+	return nil
+}
+
+func (h *Hub) unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := h.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (h *Hub) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := h.authenticate(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (h *Hub) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authFunc := h.currentSettings().authFunc; authFunc != nil {
+			if err := authFunc(r.Header.Get("Authorization")); err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, fmt.Sprintf("authentication failed: %v", err), http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,35 +1,167 @@
 package hub
 
 import (
+	"compress/flate"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 
-	ws "github.com/devodev/grpc-demo/internal/websocket"
-	"github.com/gorilla/websocket"
+	"github.com/devodev/grpc-demo/internal/certs"
+	"github.com/devodev/grpc-demo/internal/transport"
 	"github.com/hashicorp/yamux"
 )
 
+// ConnectorOption provide a way to configure a Connector.
+type ConnectorOption func(*Connector) error
+
+// WithAgentVersion sets the version reported to the hub upon registration.
+func WithAgentVersion(v string) ConnectorOption {
+	return func(c *Connector) error {
+		c.header.Set("X-Hub-Meta-Version", v)
+		return nil
+	}
+}
+
+// WithLabels sets the labels reported to the hub upon registration.
+func WithLabels(labels map[string]string) ConnectorOption {
+	return func(c *Connector) error {
+		for k, v := range labels {
+			if k == "" {
+				return fmt.Errorf("label name is empty")
+			}
+			c.header.Set(labelHeaderPrefix+k, v)
+		}
+		return nil
+	}
+}
+
+// WithConnectorWebsocketLimits sets the maximum size of the websocket messages
+// read from and written to the hub. Zero values keep the defaults.
+func WithConnectorWebsocketLimits(readLimit int64, maxFrameSize int) ConnectorOption {
+	return func(c *Connector) error {
+		if readLimit < 0 || maxFrameSize < 0 {
+			return fmt.Errorf("websocket limits must be positive")
+		}
+		c.dialer.WebsocketReadLimit = readLimit
+		c.dialer.WebsocketMaxFrameSize = maxFrameSize
+		return nil
+	}
+}
+
+// WithConnectorWebsocketWriteCoalescing enables the coalescing of small
+// writes into fewer websocket messages, see websocket.WithWriteCoalescing.
+func WithConnectorWebsocketWriteCoalescing(enabled bool) ConnectorOption {
+	return func(c *Connector) error {
+		c.dialer.WebsocketWriteCoalescing = enabled
+		return nil
+	}
+}
+
+// WithConnectorWebsocketCompression negotiates the permessage-deflate
+// extension with the hub, using the provided flate compression level.
+// Messages smaller than threshold bytes are sent uncompressed;
+// a zero threshold keeps the default.
+func WithConnectorWebsocketCompression(level, threshold int) ConnectorOption {
+	return func(c *Connector) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level: %v", level)
+		}
+		if threshold < 0 {
+			return fmt.Errorf("invalid compression threshold: %v", threshold)
+		}
+		c.dialer.WebsocketCompression = true
+		c.dialer.WebsocketCompressionLevel = level
+		c.dialer.WebsocketCompressionThreshold = threshold
+		return nil
+	}
+}
+
+// WithConnectorTLSFiles sets the client certificate presented to the hub
+// and the CA bundle used to verify it. The files are reloaded when they
+// change, so that rotated certificates are used when reconnecting.
+// The key pair and the CA bundle are both optional.
+func WithConnectorTLSFiles(certFile, keyFile, caFile string) ConnectorOption {
+	return func(c *Connector) error {
+		reloader, err := certs.NewReloader(certFile, keyFile, caFile)
+		if err != nil {
+			return err
+		}
+		c.dialer.Certificates = reloader
+		return nil
+	}
+}
+
+// WithConnectorCertRenewal renews the client certificate set with
+// WithConnectorTLSFiles through the enrollment endpoint of the hub HTTP
+// server at uri (https://host:port) before it expires, replacing its files.
+func WithConnectorCertRenewal(uri string) ConnectorOption {
+	return func(c *Connector) error {
+		reloader := c.dialer.Certificates
+		if reloader == nil || reloader.Certificate() == nil {
+			return fmt.Errorf("certificate renewal requires a client certificate")
+		}
+		u, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("certificate renewal requires an https uri: %v", uri)
+		}
+		c.renewer = &certs.Renewer{
+			Reloader:  reloader,
+			URI:       uri,
+			TLSConfig: c.dialer.TLSConfig,
+			OnRenew: func(err error) {
+				if err != nil {
+					defaultLogger.Println(err)
+					return
+				}
+				defaultLogger.Println("client certificate renewed")
+			},
+		}
+		return nil
+	}
+}
+
 // Connector is used to dial a Hub.
+//
+// The transport is selected by the scheme of the hub address:
+// ws:// or wss:// for the websocket endpoint, tls:// or h2:// for
+// the tunnel listener.
 type Connector struct {
 	addr   string
 	header http.Header
-	dialer *websocket.Dialer
+	dialer *transport.Dialer
+
+	sessionConfig *yamux.Config
+
+	// renewer is nil unless the client certificate is renewed.
+	renewer *certs.Renewer
+
+	onGoAway func(message string)
 }
 
 // NewConnector returns a connector that can reach a Hub and provide a listener
 // to be used when serving HTTP.
-func NewConnector(hubAddr string, insecureSkipVerify bool, name string) (*Connector, error) {
+func NewConnector(hubAddr string, insecureSkipVerify bool, name string, opts ...ConnectorOption) (*Connector, error) {
 	u, err := url.Parse(hubAddr)
 	if err != nil {
 		return nil, err
 	}
+	switch u.Scheme {
+	case transport.SchemeWS, transport.SchemeWSS, transport.SchemeTLS, transport.SchemeHTTP2:
+	default:
+		return nil, fmt.Errorf("unsupported hub address scheme: %q", u.Scheme)
+	}
 
-	dialer := websocket.DefaultDialer
+	dialer := &transport.Dialer{}
 	if insecureSkipVerify {
-		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
 	if name == "" {
@@ -37,39 +169,53 @@ func NewConnector(hubAddr string, insecureSkipVerify bool, name string) (*Connec
 	}
 	header := make(http.Header)
 	header.Add("X-Hub-Meta-Name", name)
+	// The instance lets the hub recognize the connector when it
+	// reconnects, see WithReconnectGracePeriod.
+	instance := make([]byte, 16)
+	if _, err := rand.Read(instance); err != nil {
+		return nil, err
+	}
+	header.Add(instanceHeader, hex.EncodeToString(instance))
 
-	return &Connector{addr: u.String(), dialer: dialer, header: header}, nil
+	c := &Connector{addr: u.String(), dialer: dialer, header: header, sessionConfig: yamux.DefaultConfig()}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
 // Listener dials the hub, wraps the underlying connection
 // as a listener and returns it.
+//
+// The listener handles the control messages sent by the hub,
+// see WithGoAwayHandler.
 func (h *Connector) Listener() (net.Listener, error) {
-	wsConn, err := h.dial()
+	conn, err := h.dial()
 	if err != nil {
 		return nil, err
 	}
-	return h.asListener(wsConn)
-}
-
-// dial returns a valid websocket connection to be used
-// as a io.ReadWriteCloser.
-func (h *Connector) dial() (*websocket.Conn, error) {
-	wsConn, _, err := h.dialer.Dial(h.addr, h.header)
+	session, err := h.asListener(conn)
 	if err != nil {
 		return nil, err
 	}
-	return wsConn, nil
+	if h.renewer != nil {
+		go h.renewer.Run(session.CloseChan())
+	}
+	return newAgentListener(session, h.onGoAway), nil
 }
 
-func (h *Connector) asListener(c *websocket.Conn) (*yamux.Session, error) {
-	wsRwc, err := ws.ReadWriteCloser(c)
-	if err != nil {
-		c.Close()
-		return nil, err
-	}
-	srvConn, err := yamux.Server(wsRwc, yamux.DefaultConfig())
+// dial returns a valid transport connection to be used
+// as a io.ReadWriteCloser.
+func (h *Connector) dial() (io.ReadWriteCloser, error) {
+	return h.dialer.Dial(h.addr, h.header)
+}
+
+func (h *Connector) asListener(c io.ReadWriteCloser) (*yamux.Session, error) {
+	srvConn, err := yamux.Server(c, h.sessionConfig)
 	if err != nil {
-		wsRwc.CloseWithMessage(err.Error())
+		closeWithMessage(c, err.Error())
 		return nil, err
 	}
 	return srvConn, nil
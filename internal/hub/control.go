@@ -0,0 +1,163 @@
+package hub
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/yamux"
+)
+
+// controlPreface starts the streams opened by the hub to send a control
+// message to an agent, rather than to proxy a call. It cannot be mistaken
+// for the HTTP/2 client preface starting the gRPC connections.
+const controlPreface = "HUB-CONTROL\n"
+
+// Control messages, sent as a single line after the preface
+// in the form of "<kind> <message>".
+const (
+	// controlGoAway announces that the hub is shutting down.
+	controlGoAway = "GOAWAY"
+)
+
+var (
+	controlWriteTimeout = 5 * time.Second
+	controlReadTimeout  = 10 * time.Second
+	maxControlSize      = 4096
+)
+
+// writeControl opens a stream on session and sends a control message.
+func writeControl(session *yamux.Session, kind, message string) error {
+	stream, err := session.OpenStream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	stream.SetWriteDeadline(time.Now().Add(controlWriteTimeout))
+	_, err = io.WriteString(stream, fmt.Sprintf("%v%v %v\n", controlPreface, kind, message))
+	return err
+}
+
+// WithGoAwayHandler sets a function called when the hub announces that
+// it is shutting down. The session stays open until the in-flight calls
+// are drained, leaving time to register again with another hub.
+func WithGoAwayHandler(f func(message string)) ConnectorOption {
+	return func(c *Connector) error {
+		c.onGoAway = f
+		return nil
+	}
+}
+
+// agentListener accepts the streams opened by the hub on the session
+// of an agent, handling control streams and returning the other ones.
+type agentListener struct {
+	*yamux.Session
+
+	onGoAway func(string)
+
+	conns     chan net.Conn
+	handling  sync.WaitGroup
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newAgentListener(session *yamux.Session, onGoAway func(string)) *agentListener {
+	l := &agentListener{
+		Session:  session,
+		onGoAway: onGoAway,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// acceptLoop hands the streams over until the session is closed. The
+// pending control messages are handled before Accept reports the closing,
+// so that a GOAWAY is not mistaken for a lost connection.
+func (l *agentListener) acceptLoop() {
+	defer l.closeOnce.Do(func() { close(l.closed) })
+	for {
+		stream, err := l.Session.AcceptStream()
+		if err != nil {
+			l.handling.Wait()
+			return
+		}
+		l.handling.Add(1)
+		go func() {
+			defer l.handling.Done()
+			l.handle(stream)
+		}()
+	}
+}
+
+// handle reads the beginning of stream to tell control streams apart.
+func (l *agentListener) handle(stream *yamux.Stream) {
+	preface := make([]byte, len(controlPreface))
+	stream.SetReadDeadline(time.Now().Add(controlReadTimeout))
+	n, err := io.ReadFull(stream, preface)
+	stream.SetReadDeadline(time.Time{})
+	if err != nil && n == 0 {
+		stream.Close()
+		return
+	}
+	if string(preface[:n]) == controlPreface {
+		l.handleControl(stream)
+		return
+	}
+	conn := &prefacedConn{Conn: stream, r: io.MultiReader(bytes.NewReader(preface[:n]), stream)}
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		stream.Close()
+	}
+}
+
+func (l *agentListener) handleControl(stream *yamux.Stream) {
+	defer stream.Close()
+	stream.SetReadDeadline(time.Now().Add(controlReadTimeout))
+	line, err := bufio.NewReader(io.LimitReader(stream, int64(maxControlSize))).ReadString('\n')
+	if err != nil {
+		return
+	}
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	var message string
+	if len(parts) == 2 {
+		message = parts[1]
+	}
+	switch parts[0] {
+	case controlGoAway:
+		if l.onGoAway != nil {
+			l.onGoAway(message)
+		}
+	}
+}
+
+func (l *agentListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, yamux.ErrSessionShutdown
+	}
+}
+
+func (l *agentListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Session.Close()
+}
+
+// prefacedConn is a stream whose first bytes were already read.
+type prefacedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefacedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
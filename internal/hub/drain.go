@@ -0,0 +1,182 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/devodev/grpc-demo/internal/client"
+	"github.com/hashicorp/yamux"
+)
+
+var (
+	errShuttingDown   = errors.New("hub is shutting down")
+	errListenerClosed = errors.New("listener closed")
+)
+
+func (h *Hub) isClosing() bool {
+	select {
+	case <-h.closingCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain shuts the hub down gracefully.
+//
+// The hub reports itself unhealthy and refuses new registrations and calls,
+// notifies the registered agents so that they can reconnect elsewhere,
+// and waits for the in-flight calls for up to the shutdown timeout
+// before closing the remaining sessions.
+func (h *Hub) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+	defer cancel()
+
+	clients := h.ClientRegistry.List()
+	h.logger.Printf("hub is shutting down: draining %d clients for up to %v", len(clients), h.shutdownTimeout)
+	// Notify the agents first so that they register elsewhere while the
+	// in-flight calls drain. Their sessions are closed once both servers
+	// are shut down.
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		if c.Type != client.TypeAgent {
+			continue
+		}
+		wg.Add(1)
+		go func(c *client.Client) {
+			defer wg.Done()
+			h.sendGoAway(c)
+		}(c)
+	}
+	wg.Wait()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		h.shutdownHTTP(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		h.shutdownGRPC(ctx)
+	}()
+	wg.Wait()
+
+	h.closeSessions()
+	h.closeUpstreams()
+	h.closeUpstreamClients()
+}
+
+func (h *Hub) shutdownHTTP(ctx context.Context) {
+	h.logger.Println("HTTP server is shutting down...")
+	h.server.SetKeepAlivesEnabled(false)
+	if err := h.server.Shutdown(ctx); err != nil {
+		h.logger.Errorf("error during server shutdown: %v", err)
+		h.server.Close()
+	}
+}
+
+// shutdownGRPC waits for the in-flight calls, including the proxied ones,
+// until ctx is done.
+func (h *Hub) shutdownGRPC(ctx context.Context) {
+	h.logger.Println("grpc server is shutting down..")
+	stopped := make(chan struct{})
+	go func() {
+		h.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		h.logger.Errorln("shutdown timeout reached, cancelling the remaining gRPC calls")
+		h.grpcServer.Stop()
+		<-stopped
+	}
+}
+
+// sendGoAway notifies the agent that the hub is shutting down.
+// Agents that do not handle control streams drop it.
+func (h *Hub) sendGoAway(c *client.Client) {
+	if err := writeControl(c.Session, controlGoAway, errShuttingDown.Error()); err != nil {
+		h.logger.Debugf("notifying client %v of the shutdown: %v", c.Name, err)
+	}
+}
+
+// trackSession records a hijacked connection to be closed once the hub
+// is drained. It returns false if the hub is already drained.
+func (h *Hub) trackSession(rwc io.ReadWriteCloser) bool {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+	if h.sessions == nil {
+		return false
+	}
+	h.sessions[rwc] = struct{}{}
+	return true
+}
+
+func (h *Hub) untrackSession(rwc io.ReadWriteCloser) {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+	delete(h.sessions, rwc)
+}
+
+func (h *Hub) closeSessions() {
+	h.sessionsMu.Lock()
+	sessions := h.sessions
+	h.sessions = nil
+	h.sessionsMu.Unlock()
+
+	if len(sessions) > 0 {
+		h.logger.Printf("closing %d sessions", len(sessions))
+	}
+	for rwc := range sessions {
+		closeWithMessage(rwc, errShuttingDown.Error())
+	}
+}
+
+// drainListener accepts the streams of a yamux session. Closing it stops
+// accepting streams without closing the session, so that the gRPC server
+// can drain the calls of a caller tunnel.
+type drainListener struct {
+	session *yamux.Session
+	once    sync.Once
+	closed  chan struct{}
+}
+
+func newDrainListener(session *yamux.Session) *drainListener {
+	return &drainListener{session: session, closed: make(chan struct{})}
+}
+
+func (l *drainListener) Accept() (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := l.session.Accept()
+		ch <- result{conn, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-l.closed:
+		go func() {
+			if r := <-ch; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, errListenerClosed
+	}
+}
+
+func (l *drainListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *drainListener) Addr() net.Addr {
+	return l.session.Addr()
+}
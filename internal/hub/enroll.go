@@ -0,0 +1,256 @@
+package hub
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/certs"
+)
+
+var (
+	defaultClientCertTTL      = 24 * time.Hour
+	defaultEnrollmentTokenTTL = time.Hour
+
+	maxEnrollRequestSize int64 = 64 << 10
+)
+
+// WithCertificateAuthority enables the internal certificate authority.
+//
+// Agents enroll using single-use tokens created with CreateEnrollmentToken
+// and receive client certificates valid for certTTL, bound to their name,
+// that they renew before expiry. A zero certTTL keeps the default (24h).
+// Client certificates are then required from the agents registering on the
+// TLS listeners, which must register with the name they are bound to, see
+// WithAllowUncertifiedAgents. Since CreateEnrollmentToken is exposed by the
+// Hub service, an AuthFunc must be set as well.
+func WithCertificateAuthority(ca *certs.Authority, certTTL time.Duration) Option {
+	return func(h *Hub) error {
+		if certTTL < 0 {
+			return fmt.Errorf("invalid client certificate ttl: %v", certTTL)
+		}
+		if certTTL == 0 {
+			certTTL = defaultClientCertTTL
+		}
+		h.certAuthority = ca
+		h.clientCertTTL = certTTL
+		return nil
+	}
+}
+
+// WithAllowUncertifiedAgents lets the agents presenting no client certificate
+// register under any name not bound to an enrolled one, such as while migrating
+// agents to enrollment. Agents presenting one are still verified.
+func WithAllowUncertifiedAgents(allow bool) Option {
+	return func(h *Hub) error {
+		h.allowUncertifiedAgents = allow
+		return nil
+	}
+}
+
+func (h *Hub) validateCertificateAuthority() error {
+	if h.certAuthority == nil {
+		return nil
+	}
+	if h.httpTLSConfig == nil {
+		return fmt.Errorf("certificate authority requires a TLS configuration")
+	}
+	if h.settings.authFunc == nil {
+		return errEnrollmentUnauthenticated
+	}
+	return nil
+}
+
+// errEnrollmentUnauthenticated is returned when the certificate authority is
+// enabled without authentication, which would let anyone create enrollment
+// tokens.
+var errEnrollmentUnauthenticated = errors.New("certificate authority requires authentication, such as auth tokens, to protect the creation of enrollment tokens")
+
+// serverTLSConfig returns the TLS configuration of the listeners.
+// When the certificate authority is enabled, client certificates
+// are requested and verified by the handlers.
+func (h *Hub) serverTLSConfig() *tls.Config {
+	if h.httpTLSConfig == nil || h.certAuthority == nil {
+		return h.httpTLSConfig
+	}
+	c := h.httpTLSConfig.Clone()
+	if c.ClientAuth == tls.NoClientCert {
+		c.ClientAuth = tls.RequestClientCert
+	}
+	if verify := c.VerifyPeerCertificate; verify != nil {
+		// The certificates issued to enrolled agents are
+		// not part of the CA bundle of the TLS configuration.
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+			err := verify(rawCerts, chains)
+			if err == nil {
+				return nil
+			}
+			if chain, perr := certs.ParseChain(rawCerts); perr == nil && len(chain) > 0 {
+				if _, verr := h.certAuthority.VerifyClient(chain); verr == nil {
+					return nil
+				}
+			}
+			return err
+		}
+	}
+	return c
+}
+
+// enrollmentToken is a single-use token allowing an agent to enroll.
+type enrollmentToken struct {
+	name    string
+	expires time.Time
+}
+
+// CreateEnrollmentToken returns a single-use token allowing the agent
+// named name to enroll until it expires after ttl. A zero ttl keeps
+// the default (1h).
+func (h *Hub) CreateEnrollmentToken(name string, ttl time.Duration) (string, time.Time, error) {
+	if h.certAuthority == nil {
+		return "", time.Time{}, fmt.Errorf("certificate authority is not enabled")
+	}
+	if name == "" {
+		return "", time.Time{}, fmt.Errorf("name is empty")
+	}
+	if ttl < 0 {
+		return "", time.Time{}, fmt.Errorf("invalid token ttl: %v", ttl)
+	}
+	if ttl == 0 {
+		ttl = defaultEnrollmentTokenTTL
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expires := time.Now().Add(ttl)
+
+	h.enrollMu.Lock()
+	now := time.Now()
+	for t, et := range h.enrollTokens {
+		if now.After(et.expires) {
+			delete(h.enrollTokens, t)
+		}
+	}
+	h.enrollTokens[token] = enrollmentToken{name: name, expires: expires}
+	h.enrollMu.Unlock()
+
+	h.activityFeed.Send(fmt.Sprintf("created enrollment token for client: %v", name))
+	return token, expires, nil
+}
+
+// useEnrollmentToken consumes token and returns the name it was created for.
+func (h *Hub) useEnrollmentToken(token string) (string, error) {
+	h.enrollMu.Lock()
+	defer h.enrollMu.Unlock()
+	et, ok := h.enrollTokens[token]
+	if !ok {
+		return "", fmt.Errorf("invalid enrollment token")
+	}
+	delete(h.enrollTokens, token)
+	if time.Now().After(et.expires) {
+		return "", fmt.Errorf("enrollment token expired")
+	}
+	return et.name, nil
+}
+
+// checkClientCertificate verifies that the client certificate presented
+// by an agent was issued by the certificate authority for name. Agents
+// presenting none are refused unless WithAllowUncertifiedAgents is set.
+func (h *Hub) checkClientCertificate(name string, state *tls.ConnectionState) error {
+	if h.certAuthority == nil {
+		return nil
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		if h.allowUncertifiedAgents {
+			return nil
+		}
+		return fmt.Errorf("client certificate required to register %q: enroll the agent first", name)
+	}
+	certName, err := h.certAuthority.VerifyClient(state.PeerCertificates)
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %v", err)
+	}
+	if certName != name {
+		return fmt.Errorf("client certificate is bound to %q, not %q", certName, name)
+	}
+	return nil
+}
+
+// handleEnroll signs a client certificate for an agent presenting
+// a valid enrollment token.
+func (h *Hub) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.readEnrollRequest(w, r)
+	if !ok {
+		return
+	}
+	name, err := h.useEnrollmentToken(req.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if h.signClientCertificate(w, req, name) {
+		h.activityFeed.Send(fmt.Sprintf("enrolled client with name: %v", name))
+	}
+}
+
+// handleRenewCertificate signs a new client certificate for an agent
+// presenting a valid one.
+func (h *Hub) handleRenewCertificate(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.readEnrollRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(r.TLS.PeerCertificates) == 0 {
+		http.Error(w, "client certificate required", http.StatusUnauthorized)
+		return
+	}
+	name, err := h.certAuthority.VerifyClient(r.TLS.PeerCertificates)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid client certificate: %v", err), http.StatusForbidden)
+		return
+	}
+	if h.signClientCertificate(w, req, name) {
+		h.activityFeed.Send(fmt.Sprintf("renewed certificate of client: %v", name))
+	}
+}
+
+func (h *Hub) readEnrollRequest(w http.ResponseWriter, r *http.Request) (*certs.EnrollRequest, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if h.certAuthority == nil {
+		http.Error(w, "certificate authority is not enabled", http.StatusNotFound)
+		return nil, false
+	}
+	if r.TLS == nil {
+		http.Error(w, "enrollment requires https", http.StatusForbidden)
+		return nil, false
+	}
+	var req certs.EnrollRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEnrollRequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+func (h *Hub) signClientCertificate(w http.ResponseWriter, req *certs.EnrollRequest, name string) bool {
+	cert, err := h.certAuthority.Sign([]byte(req.CSR), name, h.clientCertTTL)
+	if err != nil {
+		h.logger.Errorf("signing certificate of client %v: %v", name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&certs.EnrollResponse{Certificate: string(cert)})
+	return true
+}
@@ -0,0 +1,90 @@
+package hub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details
+// of the errors returned when routing a call fails.
+const ErrorDomain = "hub.grpc-demo"
+
+// Reasons of the ErrorInfo details of the routing errors.
+const (
+	// ReasonTargetMissing is returned with InvalidArgument when neither
+	// the call metadata nor its authority name the client it is for.
+	ReasonTargetMissing = "TARGET_MISSING"
+
+	// ReasonClientNotFound is returned with NotFound when no client
+	// is registered with the name of the call metadata, or of the route.
+	ReasonClientNotFound = "CLIENT_NOT_FOUND"
+
+	// ReasonNoClientMatches is returned with Unavailable when no client
+	// has the labels of the route, the call being retried once one registers.
+	ReasonNoClientMatches = "NO_CLIENT_MATCHES"
+
+	// ReasonClientUnavailable is returned with Unavailable when the client
+	// is disconnected, the call being retried once it reconnects.
+	ReasonClientUnavailable = "CLIENT_UNAVAILABLE"
+
+	// ReasonHubShuttingDown is returned with Unavailable when the hub
+	// drains its calls, the call being retried on another hub.
+	ReasonHubShuttingDown = "HUB_SHUTTING_DOWN"
+)
+
+// defaultRetryDelay is the delay suggested by the RetryInfo details
+// of the routing errors that can be retried.
+var defaultRetryDelay = time.Second
+
+// routingError returns a status error with an ErrorInfo detail and,
+// if retryDelay is positive, a RetryInfo detail.
+func routingError(code codes.Code, reason string, metadata map[string]string, retryDelay time.Duration, format string, a ...interface{}) error {
+	s := status.New(code, fmt.Sprintf(format, a...))
+	details := []proto.Message{&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	}}
+	if retryDelay > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryDelay)})
+	}
+	if withDetails, err := s.WithDetails(details...); err == nil {
+		s = withDetails
+	}
+	return s.Err()
+}
+
+func errTargetMissing(pattern *authorityPattern) error {
+	if pattern != nil {
+		return routingError(codes.InvalidArgument, ReasonTargetMissing, map[string]string{"metadataKey": "name", "authorityPattern": pattern.pattern}, 0,
+			"the name of the target client is missing from the call metadata and the authority does not match %v", pattern.pattern)
+	}
+	return routingError(codes.InvalidArgument, ReasonTargetMissing, map[string]string{"metadataKey": "name"}, 0,
+		"the name of the target client is missing from the call metadata")
+}
+
+func errClientNotFound(name string) error {
+	return routingError(codes.NotFound, ReasonClientNotFound, map[string]string{"name": name}, 0,
+		"no client registered with name %v", name)
+}
+
+func errNoClientMatches(selector string) error {
+	return routingError(codes.Unavailable, ReasonNoClientMatches, map[string]string{"labels": selector}, defaultRetryDelay,
+		"no client registered with labels %v", selector)
+}
+
+func errClientUnavailable(name string) error {
+	return routingError(codes.Unavailable, ReasonClientUnavailable, map[string]string{"name": name}, defaultRetryDelay,
+		"client %v is disconnected", name)
+}
+
+func errHubShuttingDown() error {
+	return routingError(codes.Unavailable, ReasonHubShuttingDown, nil, defaultRetryDelay,
+		"hub is shutting down")
+}
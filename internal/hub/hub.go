@@ -1,9 +1,12 @@
 package hub
 
 import (
+	"bufio"
+	"compress/flate"
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -11,19 +14,20 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	api "github.com/devodev/grpc-demo/internal/api/local"
+	"github.com/devodev/grpc-demo/internal/certs"
 	"github.com/devodev/grpc-demo/internal/client"
+	"github.com/devodev/grpc-demo/internal/dashboard"
 	"github.com/devodev/grpc-demo/internal/feed"
 	ws "github.com/devodev/grpc-demo/internal/websocket"
 
 	"github.com/gorilla/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"github.com/mwitkow/grpc-proxy/proxy"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 )
 
 var (
@@ -38,6 +42,11 @@ var (
 	defaultLogger    = log.New(defaultLogOutput, "hub: ", log.LstdFlags)
 
 	defaultShutdownTimeout = 30 * time.Second
+
+	labelHeaderPrefix = "X-Hub-Meta-Label-"
+
+	// Read buffer size of the websocket connections.
+	wsBufferSize = 4096
 )
 
 // Middleware is used to decorate an http.Handler.
@@ -74,6 +83,7 @@ func WithGRPCListenAddr(a string) Option {
 }
 
 // WithTimeouts sets the timeout values of the http server.
+// The write timeout does not apply to streamed responses.
 func WithTimeouts(read, write, idle time.Duration) Option {
 	return func(h *Hub) error {
 		h.httpReadTimeout = read
@@ -99,14 +109,83 @@ func WithTLSConfig(c *tls.Config) Option {
 	}
 }
 
-// WithShutdownTimeout sets the tlsConfig of the http server.
+// WithShutdownTimeout sets the time allowed to the in-flight calls
+// to complete when the hub is closed, see Close.
 func WithShutdownTimeout(t time.Duration) Option {
 	return func(h *Hub) error {
+		if t <= 0 {
+			return fmt.Errorf("invalid shutdown timeout: %v", t)
+		}
 		h.shutdownTimeout = t
 		return nil
 	}
 }
 
+// WithGRPCWebAllowedOrigins sets the origins allowed to make
+// cross-origin gRPC-Web requests. Use "*" to allow any origin.
+func WithGRPCWebAllowedOrigins(origins ...string) Option {
+	return func(h *Hub) error {
+		h.settings.grpcWebAllowedOrigins = append(h.settings.grpcWebAllowedOrigins, origins...)
+		return nil
+	}
+}
+
+// WithGRPCMaxMessageSize sets the maximum size of the gRPC messages
+// received and sent by the gRPC server, including proxied messages.
+// A zero value keeps the gRPC default (4MiB).
+func WithGRPCMaxMessageSize(n int) Option {
+	return func(h *Hub) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max message size: %v", n)
+		}
+		h.grpcMaxMessageSize = n
+		return nil
+	}
+}
+
+// WithWebsocketLimits sets the maximum size of the websocket messages
+// read from and written to remote servers and callers.
+// The frame size is further bounded by the read limit advertised by the peer.
+// Zero values keep the defaults.
+func WithWebsocketLimits(readLimit int64, maxFrameSize int) Option {
+	return func(h *Hub) error {
+		if readLimit < 0 || maxFrameSize < 0 {
+			return fmt.Errorf("websocket limits must be positive")
+		}
+		h.settings.wsReadLimit = readLimit
+		h.settings.wsMaxFrameSize = maxFrameSize
+		return nil
+	}
+}
+
+// WithWebsocketWriteCoalescing enables the coalescing of small writes
+// into fewer websocket messages, see websocket.WithWriteCoalescing.
+func WithWebsocketWriteCoalescing(enabled bool) Option {
+	return func(h *Hub) error {
+		h.settings.wsWriteCoalescing = enabled
+		return nil
+	}
+}
+
+// WithWebsocketCompression enables the permessage-deflate extension on the
+// websocket endpoints, using the provided flate compression level.
+// Messages smaller than threshold bytes are sent uncompressed;
+// a zero threshold keeps the default.
+func WithWebsocketCompression(level, threshold int) Option {
+	return func(h *Hub) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level: %v", level)
+		}
+		if threshold < 0 {
+			return fmt.Errorf("invalid compression threshold: %v", threshold)
+		}
+		h.settings.wsCompression = true
+		h.settings.wsCompressionLevel = level
+		h.settings.wsCompressionThreshold = threshold
+		return nil
+	}
+}
+
 // Hub acts as a gRPC proxy.
 //
 // It runs an HTTP server exposing a websocket endpoint
@@ -114,9 +193,13 @@ func WithShutdownTimeout(t time.Duration) Option {
 //
 // It also exposes a gRPC server that let clients
 // make requests against remote server services or the hub own services.
+// The same gRPC server is reachable from browsers using gRPC-Web
+// on the HTTP server.
 //
-// For a request to be proxied to a remote server, the client must include
-// a gRPC metadata map containing hub specific authentication fields.
+// The calls that are not served by the hub services are routed using
+// a routing table, see WithRoutes. By default, for a request to be proxied
+// to a remote server, the client must include a gRPC metadata map
+// containing hub specific authentication fields.
 //
 // Connector is provided as a helper for dialing in and registering to a hub.
 // It sets the correct gRPC metadata and returns a listener that can be used
@@ -124,11 +207,21 @@ func WithShutdownTimeout(t time.Duration) Option {
 type Hub struct {
 	ClientRegistry client.Registry
 
-	logger       *log.Logger
+	logger       *levelLogger
 	server       *http.Server
 	activityFeed *feed.Feed
+	hubService   *api.HubService
+	grpcServer   *grpc.Server
+
+	// settings can be changed at runtime, see Reload.
+	settingsMu sync.RWMutex
+	settings   settings
+	reloadMu   sync.Mutex
+
+	grpcListenAddr     string
+	grpcMaxMessageSize int
 
-	grpcListenAddr string
+	tunnelListenAddr string
 
 	httpListenAddr   string
 	httpReadTimeout  time.Duration
@@ -139,18 +232,41 @@ type Hub struct {
 
 	shutdownTimeout time.Duration
 
+	// certAuthority is nil unless agents can enroll, see WithCertificateAuthority.
+	certAuthority          *certs.Authority
+	clientCertTTL          time.Duration
+	allowUncertifiedAgents bool
+	enrollMu               sync.Mutex
+	enrollTokens           map[string]enrollmentToken
+
+	// sessions holds the hijacked connections of agents and callers,
+	// closed once the hub is drained. It is nil afterwards.
+	sessionsMu sync.Mutex
+	sessions   map[io.ReadWriteCloser]struct{}
+
+	// reservations holds the names of the agents expected to reconnect.
+	reservationsMu sync.Mutex
+	reservations   map[string]*reservation
+
+	// upstreams holds the connections to the upstreams of the routes.
+	upstreamsMu sync.Mutex
+	upstreams   map[string]*grpc.ClientConn
+
+	// upstreamBackends are registered in the client registry.
+	upstreamBackends []Upstream
+
+	startTime  time.Time
 	once       *sync.Once
 	closingCh  chan struct{}
 	shutdownCh chan struct{}
 }
 
-// New .
-func New(opts ...Option) (*Hub, error) {
-	h := &Hub{
-		ClientRegistry: client.NewRegistryMem(),
+// defaultHub returns a hub holding the default configuration.
+func defaultHub() *Hub {
+	return &Hub{
+		logger: newLevelLogger(defaultLogger),
 
-		logger:       defaultLogger,
-		activityFeed: feed.New(),
+		settings: defaultSettings(),
 
 		grpcListenAddr: defaultGRPCListenAddr,
 
@@ -158,14 +274,23 @@ func New(opts ...Option) (*Hub, error) {
 		httpReadTimeout:  defaultHTTPReadTimeout,
 		httpWriteTimeout: defaultHTTPWriteTimeout,
 		httpIdleTimeout:  defaultHTTPIdleTimeout,
-		httpMiddlewares:  []Middleware{tracingMiddleware, loggingMiddleware(defaultLogger)},
 
 		shutdownTimeout: defaultShutdownTimeout,
-
-		once:       &sync.Once{},
-		closingCh:  make(chan struct{}),
-		shutdownCh: make(chan struct{}),
 	}
+}
+
+// New .
+func New(opts ...Option) (*Hub, error) {
+	h := defaultHub()
+	h.ClientRegistry = client.NewRegistryMem()
+	h.activityFeed = feed.New()
+	h.once = &sync.Once{}
+	h.closingCh = make(chan struct{})
+	h.shutdownCh = make(chan struct{})
+	h.enrollTokens = make(map[string]enrollmentToken)
+	h.sessions = make(map[io.ReadWriteCloser]struct{})
+	h.reservations = make(map[string]*reservation)
+	h.upstreams = make(map[string]*grpc.ClientConn)
 
 	for _, opt := range opts {
 		if err := opt(h); err != nil {
@@ -173,6 +298,25 @@ func New(opts ...Option) (*Hub, error) {
 		}
 	}
 
+	if err := h.validateTunnel(); err != nil {
+		return nil, err
+	}
+	if err := h.validateCertificateAuthority(); err != nil {
+		return nil, err
+	}
+	h.logger.setLevel(h.settings.logLevel)
+	if err := h.registerUpstreams(); err != nil {
+		return nil, err
+	}
+
+	h.hubService = &api.HubService{Registry: h.ClientRegistry, ActivityFeed: h.activityFeed}
+	if h.certAuthority != nil {
+		h.hubService.Enrollment = h
+	}
+	h.grpcServer = h.newGRPCServer()
+	h.server = h.newHTTPServer()
+	h.startTime = time.Now()
+
 	go h.activityFeed.StartRouter(h.closingCh)
 	go func() {
 		ch := h.activityFeed.GetCh(h.closingCh)
@@ -188,63 +332,46 @@ func New(opts ...Option) (*Hub, error) {
 
 	go h.listenAndServe()
 	go h.listenAndServeGRPC()
+	if h.tunnelListenAddr != "" {
+		go h.listenAndServeTunnel()
+	}
 
 	return h, nil
 }
 
-// Close .
+// Close shuts the hub down gracefully, see drain.
+// It returns once the shutdown is complete.
 func (h *Hub) Close() {
 	h.once.Do(func() {
 		close(h.closingCh)
-		<-h.shutdownCh
+		h.drain()
+		close(h.shutdownCh)
 	})
+	<-h.shutdownCh
 }
 
-func (h *Hub) listenAndServeGRPC() {
-	director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
-		if strings.HasPrefix(fullMethodName, "/internal.") {
-			return nil, nil, grpc.Errorf(codes.Unimplemented, "Unknown method")
-		}
-		if strings.HasPrefix(fullMethodName, "/external.") {
-			md, ok := metadata.FromIncomingContext(ctx)
-			if !ok {
-				return nil, nil, grpc.Errorf(codes.FailedPrecondition, "no metadata provided")
-			}
-			nameList, ok := md["name"]
-			if !ok {
-				return nil, nil, grpc.Errorf(codes.FailedPrecondition, "name not found in metadata")
-			}
-			name := nameList[0]
-			client, err := h.ClientRegistry.Get(name)
-			if err != nil {
-				return nil, nil, grpc.Errorf(codes.FailedPrecondition, err.Error())
-			}
-			conn, err := grpc.DialContext(ctx, fullMethodName,
-				grpc.WithCodec(proxy.Codec()),
-				grpc.WithInsecure(),
-				grpc.WithDialer(func(s string, d time.Duration) (net.Conn, error) {
-					return client.Session.Open()
-				}),
-			)
-			h.activityFeed.Send(fmt.Sprintf("proxying gRPC request (%v) to: %v", fullMethodName, name))
-			return ctx, conn, err
-		}
-		return nil, nil, grpc.Errorf(codes.Unimplemented, "Unknown method")
-	}
-
-	server := grpc.NewServer(
+// newGRPCServer returns the gRPC server serving the hub services
+// and proxying every other call through the director.
+func (h *Hub) newGRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{
 		grpc.CustomCodec(proxy.Codec()),
-		grpc.UnknownServiceHandler(proxy.TransparentHandler(director)),
-	)
-	hubService := &api.HubService{Registry: h.ClientRegistry, ActivityFeed: h.activityFeed}
-	hubService.RegisterServer(server)
-
-	go func() {
-		<-h.closingCh
+		grpc.UnknownServiceHandler(proxy.TransparentHandler(h.director)),
+		grpc.UnaryInterceptor(h.unaryAuthInterceptor),
+		grpc.StreamInterceptor(h.streamAuthInterceptor),
+	}
+	if h.grpcMaxMessageSize > 0 {
+		opts = append(opts,
+			grpc.MaxRecvMsgSize(h.grpcMaxMessageSize),
+			grpc.MaxSendMsgSize(h.grpcMaxMessageSize),
+		)
+	}
+	server := grpc.NewServer(opts...)
+	h.hubService.RegisterServer(server)
+	return server
+}
 
-		h.logger.Println("grpc server is shutting down..")
-		server.GracefulStop()
-	}()
+func (h *Hub) listenAndServeGRPC() {
+	server := h.grpcServer
 
 	l, err := net.Listen("tcp", h.grpcListenAddr)
 	if err != nil {
@@ -254,108 +381,363 @@ func (h *Hub) listenAndServeGRPC() {
 
 	h.logger.Printf("gRPC server listening on: %v", h.grpcListenAddr)
 	if err := server.Serve(l); err != nil && err != grpc.ErrServerStopped {
-		h.logger.Printf("gRPC server listen error: %v", err)
+		h.logger.Errorf("gRPC server listen error: %v", err)
 	}
 }
 
-func (h *Hub) listenAndServe() {
-	var healthy int64
+// newHTTPServer returns the HTTP server serving the websocket endpoints,
+// the HTTP/JSON API, the dashboard and gRPC-Web.
+func (h *Hub) newHTTPServer() *http.Server {
 	handleHealth := func(w http.ResponseWriter, req *http.Request) {
-		if health := atomic.LoadInt64(&healthy); health != 0 {
-			fmt.Fprintf(w, "uptime: %s\n", time.Since(time.Unix(0, health)))
+		if h.isClosing() {
+			w.WriteHeader(http.StatusServiceUnavailable)
 			return
 		}
-		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "uptime: %s\n", time.Since(h.startTime))
 	}
 
 	router := http.NewServeMux()
 	router.HandleFunc("/health", handleHealth)
 	router.HandleFunc("/ws", h.handleWS)
+	router.HandleFunc("/ws/caller", h.handleCallerWS)
+	router.HandleFunc(certs.EnrollPath, h.handleEnroll)
+	router.HandleFunc(certs.RenewPath, h.handleRenewCertificate)
+	router.Handle(api.GatewayPrefix, h.authMiddleware(h.hubService.Handler()))
+	router.Handle(dashboard.Prefix, dashboard.Handler())
+	router.Handle(dashboard.EventsPath, h.authMiddleware(dashboard.EventsHandler(h.activityFeed)))
+
+	grpcWeb := grpcweb.WrapServer(h.grpcServer,
+		grpcweb.WithOriginFunc(h.grpcWebOriginAllowed),
+		grpcweb.WithCorsForRegisteredEndpointsOnly(false),
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if grpcWeb.IsGrpcWebRequest(r) || grpcWeb.IsAcceptableGrpcCorsRequest(r) {
+			// Server streaming calls last as long as the caller wants.
+			h.setWriteDeadline(r, true)
+			grpcWeb.ServeHTTP(w, r)
+			return
+		}
+		h.setWriteDeadline(r, streamingPaths[strings.TrimSuffix(r.URL.Path, "/")])
+		router.ServeHTTP(w, r)
+	})
 
-	h.server = &http.Server{
-		Addr:         h.httpListenAddr,
-		Handler:      chainMiddlewares(router, h.httpMiddlewares...),
-		ErrorLog:     h.logger,
-		TLSConfig:    h.httpTLSConfig,
-		ReadTimeout:  h.httpReadTimeout,
-		WriteTimeout: h.httpWriteTimeout,
-		IdleTimeout:  h.httpIdleTimeout,
+	return &http.Server{
+		Addr:      h.httpListenAddr,
+		Handler:   chainMiddlewares(handler, append(defaultMiddlewares(h.logger), h.httpMiddlewares...)...),
+		ErrorLog:  h.logger.Logger,
+		TLSConfig: h.serverTLSConfig(),
+		// The write timeout is applied per request, see setWriteDeadline.
+		ReadTimeout: h.httpReadTimeout,
+		IdleTimeout: h.httpIdleTimeout,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		},
 	}
+}
 
-	go func() {
-		<-h.closingCh
-
-		atomic.StoreInt64(&healthy, 0)
-		h.logger.Println("HTTP server is shutting down...")
-
-		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
-		defer cancel()
+// connContextKey is the context key of the connection of an HTTP request.
+type connContextKey struct{}
 
-		h.server.SetKeepAlivesEnabled(false)
-		if err := h.server.Shutdown(ctx); err != nil {
-			h.logger.Printf("error during server shutdown: %v", err)
-		}
-		close(h.shutdownCh)
-	}()
+// streamingPaths are the paths of the responses streamed for as long
+// as the client stays, to which the write timeout does not apply.
+var streamingPaths = map[string]bool{
+	api.GatewayPrefix + "activity": true,
+	dashboard.EventsPath:           true,
+}
 
-	atomic.StoreInt64(&healthy, time.Now().UnixNano())
+// setWriteDeadline applies the write timeout to the response of r, or lifts
+// it for streamed responses. The HTTP server has no write timeout of its own
+// since handlers cannot lift it. HTTP/2 responses are bounded by flow control
+// and the idle timeout instead, their connection being shared.
+func (h *Hub) setWriteDeadline(r *http.Request, streaming bool) {
+	if r.ProtoMajor != 1 {
+		return
+	}
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return
+	}
+	var deadline time.Time
+	if !streaming && h.httpWriteTimeout > 0 {
+		deadline = time.Now().Add(h.httpWriteTimeout)
+	}
+	conn.SetWriteDeadline(deadline)
+}
 
+func (h *Hub) listenAndServe() {
 	h.logger.Printf("HTTP server listening on: %v", h.server.Addr)
 	if h.server.TLSConfig != nil {
 		if err := h.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
-			h.logger.Printf("HTTP server listen error: %v", err)
+			h.logger.Errorf("HTTP server listen error: %v", err)
 		}
 	} else {
 		if err := h.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			h.logger.Printf("HTTP server listen error: %v", err)
+			h.logger.Errorf("HTTP server listen error: %v", err)
 		}
 	}
 }
 
+func (h *Hub) grpcWebOriginAllowed(origin string) bool {
+	for _, o := range h.currentSettings().grpcWebAllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Hub) handleWS(w http.ResponseWriter, r *http.Request) {
-	upgrader := websocket.Upgrader{}
-	wsConn, err := upgrader.Upgrade(w, r, nil)
+	if h.isClosing() {
+		http.Error(w, "hub is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.checkClientCertificate(r.Header.Get("X-Hub-Meta-Name"), r.TLS); err != nil {
+		h.logger.Errorln(err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	// Meter the hijacked connection to report the compression ratio.
+	stats := &client.Stats{}
+	wsRwc, err := h.upgrade(&meteredResponseWriter{w, stats}, r)
 	if err != nil {
-		h.logger.Printf("upgrade: %v", err)
+		h.logger.Errorln(err)
 		return
 	}
 
-	// wrap websocket conn into ReadWriteCloser
-	wsRwc, err := ws.ReadWriteCloser(wsConn)
+	h.register(wsRwc, registration{
+		header:     r.Header,
+		remoteAddr: r.RemoteAddr,
+		userAgent:  r.UserAgent(),
+		tls:        r.TLS,
+		stats:      stats,
+	})
+}
+
+// meteredResponseWriter records the traffic of the hijacked connection
+// of a websocket upgrade.
+type meteredResponseWriter struct {
+	http.ResponseWriter
+	stats *client.Stats
+}
+
+func (w *meteredResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.stats.MeterConn(conn), brw, nil
+}
+
+// upgrade upgrades the request to a websocket, advertising the hub read limit,
+// and wraps the connection into a ReadWriteCloser.
+func (h *Hub) upgrade(w http.ResponseWriter, r *http.Request) (*ws.RWC, error) {
+	s := h.currentSettings()
+
+	header := make(http.Header)
+	ws.SetReadLimitHeader(header, s.wsReadLimit)
+
+	upgrader := websocket.Upgrader{
+		// A read buffer size is set so that reads go through
+		// the hijacked connection rather than its buffered reader.
+		ReadBufferSize:    wsBufferSize,
+		EnableCompression: s.wsCompression,
+	}
+	wsConn, err := upgrader.Upgrade(w, r, header)
+	if err != nil {
+		return nil, fmt.Errorf("upgrade: %v", err)
+	}
+	opts := []ws.RWCOption{
+		ws.WithReadLimit(s.wsReadLimit),
+		ws.WithMaxFrameSize(s.wsMaxFrameSize),
+		ws.WithPeerReadLimit(r.Header),
+	}
+	if s.wsWriteCoalescing {
+		opts = append(opts, ws.WithWriteCoalescing())
+	}
+	if s.wsCompression {
+		opts = append(opts,
+			ws.WithCompression(s.wsCompressionLevel),
+			ws.WithCompressionThreshold(s.wsCompressionThreshold),
+		)
+	}
+	wsRwc, err := ws.ReadWriteCloser(wsConn, opts...)
 	if err != nil {
 		wsConn.Close()
-		h.logger.Println(err)
-		return
+		return nil, err
 	}
+	h.logger.Debugf("websocket upgrade from %v: max frame size: %v, compression: %v", r.RemoteAddr, wsRwc.MaxFrameSize(), s.wsCompression)
+	return wsRwc, nil
+}
+
+// registration holds the attributes provided by a remote server
+// when dialing the hub, whatever the transport used.
+type registration struct {
+	header     http.Header
+	remoteAddr string
+	userAgent  string
+	tls        *tls.ConnectionState
 
-	metaName := r.Header.Get("X-Hub-Meta-Name")
-	cc, err := client.New(wsRwc, metaName)
+	// stats is nil unless the transport meters the wire traffic.
+	stats *client.Stats
+}
+
+func (r registration) name() string {
+	return r.header.Get("X-Hub-Meta-Name")
+}
+
+// register creates a client using the provided transport connection
+// and adds it to the registry until its session is closed.
+func (h *Hub) register(rwc io.ReadWriteCloser, r registration) (*client.Client, error) {
+	metaName := r.name()
+	identity := r.identity()
+	sessionConfig := h.currentSettings().sessionConfig
+	cc, err := client.New(rwc, metaName,
+		client.WithRemoteAddr(r.remoteAddr),
+		client.WithUserAgent(r.userAgent),
+		client.WithAgentVersion(r.header.Get("X-Hub-Meta-Version")),
+		client.WithTLSConnectionState(r.tls),
+		client.WithLabels(labelsFromHeader(r.header)),
+		client.WithStats(r.stats),
+		client.WithSessionConfig(sessionConfig),
+	)
 	if err != nil {
-		wsRwc.CloseWithMessage(err.Error())
-		h.logger.Println(err)
+		closeWithMessage(rwc, err.Error())
+		h.logger.Errorln(err)
 		if _, ok := err.(*client.ErrEmptyAttribute); ok {
-			h.logger.Println("have you set the X-Hub-Meta-* headers?")
+			h.logger.Errorln("have you set the X-Hub-Meta-* headers?")
+		}
+		return nil, err
+	}
+
+	if !h.trackSession(rwc) {
+		cc.Session.Close()
+		closeWithMessage(rwc, errShuttingDown.Error())
+		return nil, errShuttingDown
+	}
+	reconnected, err := h.registerReserved(cc, metaName, identity)
+	if err != nil {
+		h.untrackSession(rwc)
+		cc.Session.Close()
+		closeWithMessage(rwc, err.Error())
+		h.logger.Errorln(err)
+		return nil, err
+	}
+	message := fmt.Sprintf("registered client with name: %v", metaName)
+	if reconnected {
+		message += " (reconnected within its grace period)"
+	}
+	h.activityFeed.Send(message)
+	h.logger.Printf("client %v session config: %v", metaName, describeSessionConfig(sessionConfig))
+
+	go func() {
+		<-cc.Session.CloseChan()
+		h.untrackSession(rwc)
+		message := fmt.Sprintf("unregistered client with name: %v", metaName)
+		if grace := h.unregisterReserved(metaName, identity); grace > 0 {
+			message += fmt.Sprintf(" (name reserved for %v)", grace)
 		}
+		h.activityFeed.Send(message)
+	}()
+	return cc, nil
+}
+
+// checkRegistration validates a registration before accepting a transport
+// that cannot report errors once established.
+func (h *Hub) checkRegistration(r registration) error {
+	if h.isClosing() {
+		return fmt.Errorf("hub is shutting down")
+	}
+	if r.name() == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if _, err := h.ClientRegistry.Get(r.name()); err == nil {
+		return fmt.Errorf("registration failed because client Name %v already exists", r.name())
+	}
+	if err := h.checkReservation(r.name(), r.identity()); err != nil {
+		return err
+	}
+	return h.checkClientCertificate(r.name(), r.tls)
+}
+
+func closeWithMessage(rwc io.ReadWriteCloser, m string) error {
+	if c, ok := rwc.(interface{ CloseWithMessage(string) error }); ok {
+		return c.CloseWithMessage(m)
+	}
+	return rwc.Close()
+}
+
+// handleCallerWS lets callers that cannot reach the gRPC listener
+// tunnel their calls through a websocket.
+//
+// The websocket is wrapped in a yamux client session and the hub gRPC
+// server is served over it, each stream opened by the caller
+// being accepted as a new connection.
+func (h *Hub) handleCallerWS(w http.ResponseWriter, r *http.Request) {
+	if h.isClosing() {
+		http.Error(w, "hub is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	wsRwc, err := h.upgrade(w, r)
+	if err != nil {
+		h.logger.Errorln(err)
 		return
 	}
 
-	if err := h.ClientRegistry.Register(cc, metaName); err != nil {
+	sessionConfig := h.currentSettings().sessionConfig
+	session, err := yamux.Client(wsRwc, sessionConfig)
+	if err != nil {
 		wsRwc.CloseWithMessage(err.Error())
-		h.logger.Println(err)
+		h.logger.Errorln(err)
+		return
 	}
-	h.activityFeed.Send(fmt.Sprintf("registered client with name: %v", metaName))
+	if !h.trackSession(wsRwc) {
+		session.Close()
+		wsRwc.CloseWithMessage(errShuttingDown.Error())
+		return
+	}
+	h.activityFeed.Send(fmt.Sprintf("caller tunnel opened from: %v", r.RemoteAddr))
+	h.logger.Printf("caller tunnel from %v session config: %v", r.RemoteAddr, describeSessionConfig(sessionConfig))
 
 	go func() {
-		defer h.ClientRegistry.Unregister(metaName)
-		select {
-		case <-cc.Session.CloseChan():
-			h.activityFeed.Send(fmt.Sprintf("unregistered client with name: %v", metaName))
-			return
+		defer h.untrackSession(wsRwc)
+		// The gRPC server closes its listeners when stopping; the session
+		// is only closed once its calls are drained.
+		err := h.grpcServer.Serve(newDrainListener(session))
+		if err != nil && err != grpc.ErrServerStopped && !session.IsClosed() {
+			h.logger.Errorf("caller tunnel serve error: %v", err)
 		}
+		if h.isClosing() {
+			<-session.CloseChan()
+		}
+		session.Close()
+		h.activityFeed.Send(fmt.Sprintf("caller tunnel closed from: %v", r.RemoteAddr))
 	}()
 }
 
-func loggingMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
+// labelsFromHeader returns the labels found in X-Hub-Meta-Label-* headers.
+// Label names are lowercased since header names are case-insensitive.
+func labelsFromHeader(header http.Header) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range header {
+		if !strings.HasPrefix(k, labelHeaderPrefix) || len(v) == 0 {
+			continue
+		}
+		labels[strings.ToLower(strings.TrimPrefix(k, labelHeaderPrefix))] = v[0]
+	}
+	return labels
+}
+
+// defaultMiddlewares returns the middlewares used on the http server
+// before the ones added using WithMiddlewares.
+func defaultMiddlewares(logger *levelLogger) []Middleware {
+	return []Middleware{tracingMiddleware, loggingMiddleware(logger)}
+}
+
+func loggingMiddleware(logger *levelLogger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
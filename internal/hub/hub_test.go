@@ -0,0 +1,245 @@
+package hub
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/hubclient"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// freeAddr returns a local address that is free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startTestHub starts a hub listening on free local addresses and waits
+// until its HTTP server is ready.
+func startTestHub(t *testing.T, opts ...Option) *Hub {
+	t.Helper()
+	opts = append([]Option{
+		WithHTTPListenAddr(freeAddr(t)),
+		WithGRPCListenAddr(freeAddr(t)),
+		WithShutdownTimeout(time.Second),
+		WithLogLevel(LogLevelError),
+	}, opts...)
+	h, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(h.httpURL() + "/health")
+		if err == nil {
+			resp.Body.Close()
+			return h
+		}
+		if time.Now().After(deadline) {
+			h.Close()
+			t.Fatalf("hub not ready: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (h *Hub) httpURL() string {
+	return "http://" + h.httpListenAddr
+}
+
+// testStreamOutlivesWriteTimeout reads the stream at path, filled by
+// messages sent to the activity feed, for longer than the write timeout.
+// Its lines must start with one of prefixes.
+func testStreamOutlivesWriteTimeout(t *testing.T, path string, prefixes ...string) {
+	writeTimeout := 200 * time.Millisecond
+	h := startTestHub(t, WithTimeouts(time.Second, writeTimeout, time.Second))
+	defer h.Close()
+
+	resp, err := http.Get(h.httpURL() + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: got %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				h.activityFeed.Send("tick")
+			}
+		}
+	}()
+
+	start := time.Now()
+	scanner := bufio.NewScanner(resp.Body)
+	for time.Since(start) < 4*writeTimeout {
+		if !scanner.Scan() {
+			t.Fatalf("stream ended after %v: %v", time.Since(start), scanner.Err())
+		}
+		if line := scanner.Text(); line != "" && !hasPrefix(line, prefixes) {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestActivityStreamOutlivesWriteTimeout(t *testing.T) {
+	testStreamOutlivesWriteTimeout(t, "/api/v1/activity", `{"message":`)
+}
+
+func TestDashboardEventsOutliveWriteTimeout(t *testing.T) {
+	testStreamOutlivesWriteTimeout(t, "/dashboard/events", ":", "event: activity", "data: ")
+}
+
+// deadlineConn records the write deadline set on it.
+type deadlineConn struct {
+	net.Conn
+	writeDeadline time.Time
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
+
+func TestSetWriteDeadline(t *testing.T) {
+	h := defaultHub()
+	h.httpWriteTimeout = time.Minute
+	conn := &deadlineConn{}
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req = req.WithContext(context.WithValue(req.Context(), connContextKey{}, net.Conn(conn)))
+
+	h.setWriteDeadline(req, false)
+	if d := time.Until(conn.writeDeadline); d <= 0 || d > time.Minute {
+		t.Fatalf("write deadline of a response: got %v from now, want within %v", d, time.Minute)
+	}
+	h.setWriteDeadline(req, true)
+	if !conn.writeDeadline.IsZero() {
+		t.Fatalf("write deadline of a stream: got %v, want none", conn.writeDeadline)
+	}
+}
+
+// reverseBytes answers any call by sending back the bytes it received
+// in reverse order.
+func reverseBytes(_ interface{}, stream grpc.ServerStream) error {
+	var m wrapperspb.BytesValue
+	if err := stream.RecvMsg(&m); err != nil {
+		return err
+	}
+	for i, j := 0, len(m.Value)-1; i < j; i, j = i+1, j-1 {
+		m.Value[i], m.Value[j] = m.Value[j], m.Value[i]
+	}
+	return stream.SendMsg(&m)
+}
+
+// serveTestAgent registers c as name and serves reverseBytes on its
+// listener until the returned server is stopped.
+func serveTestAgent(t *testing.T, h *Hub, c *Connector, name string, opts ...grpc.ServerOption) *grpc.Server {
+	t.Helper()
+	lis, err := c.Listener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(append(opts, grpc.UnknownServiceHandler(reverseBytes))...)
+	go srv.Serve(lis)
+	waitFor(t, "agent registration", func() bool {
+		_, err := h.ClientRegistry.Get(name)
+		return err == nil
+	})
+	return srv
+}
+
+// waitFor waits up to 5 seconds for cond to be true.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLargeMessagesThroughConnector(t *testing.T) {
+	const maxMessageSize = 8 << 20
+	// The connector reads smaller messages than the frames written by
+	// default and writes larger ones than the hub reads, so that both
+	// ends must split their writes at the read limit of their peer.
+	h := startTestHub(t,
+		WithGRPCMaxMessageSize(maxMessageSize),
+		WithWebsocketLimits(64<<10, 0),
+	)
+	defer h.Close()
+
+	c, err := NewConnector("ws://"+h.httpListenAddr+"/ws", false, "testserver",
+		WithConnectorWebsocketLimits(16<<10, 1<<20),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serveTestAgent(t, h, c, "testserver",
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+	)
+	defer srv.Stop()
+
+	conn, err := hubclient.Dial(h.grpcListenAddr, "testserver", hubclient.WithDialOptions(
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := &wrapperspb.BytesValue{Value: make([]byte, 6<<20)}
+	if _, err := rand.Read(req.Value); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, len(req.Value))
+	for i, b := range req.Value {
+		want[len(want)-1-i] = b
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var resp wrapperspb.BytesValue
+	if err := conn.Invoke(ctx, "/external.Test/Reverse", req, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp.Value, want) {
+		t.Fatalf("response of %v bytes does not match the reversed request", len(resp.Value))
+	}
+}
@@ -0,0 +1,105 @@
+package hub
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel is the minimum level of the messages logged by the hub.
+type LogLevel int32
+
+// Log levels, from the most to the least verbose.
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogLevelDebug: "debug",
+	LogLevelInfo:  "info",
+	LogLevelError: "error",
+}
+
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int32(l))
+}
+
+// ParseLogLevel returns the log level named s.
+// An empty name is the info level.
+func ParseLogLevel(s string) (LogLevel, error) {
+	if s == "" {
+		return LogLevelInfo, nil
+	}
+	for l, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level: %q", s)
+}
+
+// WithLogLevel sets the minimum level of the messages logged by the hub.
+func WithLogLevel(l LogLevel) Option {
+	return func(h *Hub) error {
+		if _, ok := logLevelNames[l]; !ok {
+			return fmt.Errorf("invalid log level: %v", l)
+		}
+		h.settings.logLevel = l
+		return nil
+	}
+}
+
+// levelLogger filters the messages of a log.Logger by level.
+// Print methods log at the info level.
+type levelLogger struct {
+	*log.Logger
+	level int32
+}
+
+func newLevelLogger(l *log.Logger) *levelLogger {
+	return &levelLogger{Logger: l, level: int32(LogLevelInfo)}
+}
+
+func (l *levelLogger) setLevel(level LogLevel) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *levelLogger) enabled(level LogLevel) bool {
+	return LogLevel(atomic.LoadInt32(&l.level)) <= level
+}
+
+func (l *levelLogger) Debugf(format string, v ...interface{}) {
+	if l.enabled(LogLevelDebug) {
+		l.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *levelLogger) Printf(format string, v ...interface{}) {
+	if l.enabled(LogLevelInfo) {
+		l.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *levelLogger) Println(v ...interface{}) {
+	if l.enabled(LogLevelInfo) {
+		l.Output(2, fmt.Sprintln(v...))
+	}
+}
+
+func (l *levelLogger) Errorf(format string, v ...interface{}) {
+	if l.enabled(LogLevelError) {
+		l.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *levelLogger) Errorln(v ...interface{}) {
+	if l.enabled(LogLevelError) {
+		l.Output(2, fmt.Sprintln(v...))
+	}
+}
@@ -0,0 +1,145 @@
+package hub
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/yamux"
+)
+
+// settings holds the hub settings that can be changed at runtime.
+// They apply to new requests and sessions.
+type settings struct {
+	authFunc              AuthFunc
+	grpcWebAllowedOrigins []string
+
+	wsReadLimit       int64
+	wsMaxFrameSize    int
+	wsWriteCoalescing bool
+
+	wsCompression          bool
+	wsCompressionLevel     int
+	wsCompressionThreshold int
+
+	sessionConfig *yamux.Config
+
+	reconnectGracePeriod time.Duration
+
+	routes           routingTable
+	authorityPattern *authorityPattern
+
+	logLevel LogLevel
+}
+
+func defaultSettings() settings {
+	routes, _ := newRoutingTable(defaultRoutes)
+	return settings{
+		routes:        routes,
+		sessionConfig: yamux.DefaultConfig(),
+		logLevel:      LogLevelInfo,
+	}
+}
+
+func (h *Hub) currentSettings() settings {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.settings
+}
+
+// Validate applies opts to a hub configuration without starting it
+// and returns the first error found.
+func Validate(opts ...Option) error {
+	h := defaultHub()
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return err
+		}
+	}
+	if err := h.validateTunnel(); err != nil {
+		return err
+	}
+	return h.validateCertificateAuthority()
+}
+
+// Reload reconfigures the running hub using opts, which describe
+// the whole configuration as with New.
+//
+// Only the settings applying to new requests and sessions are reloaded:
+// authentication, gRPC-Web allowed origins, websocket and session limits,
+// the reconnect grace period, the routing table, the authority pattern
+// and the log level. Registered clients are kept. Changes to the listeners,
+// TLS configuration, certificate authority, timeouts, gRPC message size
+// or upstreams require a restart and are ignored. The result is reported
+// in the activity feed.
+func (h *Hub) Reload(opts ...Option) error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	next := defaultHub()
+	for _, opt := range opts {
+		if err := opt(next); err != nil {
+			err = fmt.Errorf("configuration reload failed: %v", err)
+			h.activityFeed.Send(err.Error())
+			return err
+		}
+	}
+	// The running certificate authority is kept until restart.
+	if h.certAuthority != nil && next.settings.authFunc == nil {
+		err := fmt.Errorf("configuration reload failed: %v", errEnrollmentUnauthenticated)
+		h.activityFeed.Send(err.Error())
+		return err
+	}
+
+	h.settingsMu.Lock()
+	h.settings = next.settings
+	h.settingsMu.Unlock()
+	h.logger.setLevel(next.settings.logLevel)
+
+	message := "configuration reloaded"
+	if ignored := h.restartRequired(next); len(ignored) > 0 {
+		message += fmt.Sprintf(" (restart required to apply: %v)", strings.Join(ignored, ", "))
+	}
+	h.activityFeed.Send(message)
+	return nil
+}
+
+// restartRequired returns the names of the settings of next
+// that differ from the running hub and cannot be reloaded.
+func (h *Hub) restartRequired(next *Hub) []string {
+	var names []string
+	if next.httpListenAddr != h.httpListenAddr {
+		names = append(names, "HTTP listen address")
+	}
+	if next.grpcListenAddr != h.grpcListenAddr {
+		names = append(names, "gRPC listen address")
+	}
+	if next.tunnelListenAddr != h.tunnelListenAddr {
+		names = append(names, "tunnel listen address")
+	}
+	if next.httpTLSConfig != h.httpTLSConfig {
+		names = append(names, "TLS configuration")
+	}
+	if next.httpReadTimeout != h.httpReadTimeout ||
+		next.httpWriteTimeout != h.httpWriteTimeout ||
+		next.httpIdleTimeout != h.httpIdleTimeout {
+		names = append(names, "HTTP timeouts")
+	}
+	if next.shutdownTimeout != h.shutdownTimeout {
+		names = append(names, "shutdown timeout")
+	}
+	if next.grpcMaxMessageSize != h.grpcMaxMessageSize {
+		names = append(names, "gRPC max message size")
+	}
+	if next.certAuthority != h.certAuthority || next.clientCertTTL != h.clientCertTTL ||
+		next.allowUncertifiedAgents != h.allowUncertifiedAgents {
+		names = append(names, "certificate authority")
+	}
+	if !upstreamsEqual(next.upstreamBackends, h.upstreamBackends) {
+		names = append(names, "upstreams")
+	}
+	if len(next.httpMiddlewares) != len(h.httpMiddlewares) {
+		names = append(names, "HTTP middlewares")
+	}
+	return names
+}
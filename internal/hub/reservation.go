@@ -0,0 +1,141 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/client"
+)
+
+// instanceHeader identifies an agent across its reconnections,
+// see WithReconnectGracePeriod.
+const instanceHeader = "X-Hub-Meta-Instance"
+
+// WithReconnectGracePeriod keeps the name of a disconnected agent reserved
+// for d, so that only the same agent instance can register it again.
+// Proxied calls arriving in the meantime wait for the agent to reconnect,
+// bounded by their deadline. Zero, the default, releases the name right away.
+func WithReconnectGracePeriod(d time.Duration) Option {
+	return func(h *Hub) error {
+		if d < 0 {
+			return fmt.Errorf("invalid reconnect grace period: %v", d)
+		}
+		h.settings.reconnectGracePeriod = d
+		return nil
+	}
+}
+
+// reservation holds the name of a disconnected agent.
+type reservation struct {
+	identity string
+	timer    *time.Timer
+
+	// released is closed once the agent registered again
+	// or the grace period expired.
+	released chan struct{}
+}
+
+func errNameReserved(name string) error {
+	return fmt.Errorf("registration failed because client Name %v is reserved for a reconnecting client", name)
+}
+
+// identity returns the identity of the agent registering with r: the
+// instance reported by its connector and the subject of its client
+// certificate, if any. Agents that do not report an instance cannot
+// reserve their name.
+func (r registration) identity() string {
+	instance := r.header.Get(instanceHeader)
+	if instance == "" {
+		return ""
+	}
+	if r.tls != nil && len(r.tls.PeerCertificates) > 0 {
+		return instance + "/" + r.tls.PeerCertificates[0].Subject.String()
+	}
+	return instance
+}
+
+// checkReservation returns an error if name is reserved for another agent.
+func (h *Hub) checkReservation(name, identity string) error {
+	h.reservationsMu.Lock()
+	defer h.reservationsMu.Unlock()
+	if res, ok := h.reservations[name]; ok && res.identity != identity {
+		return errNameReserved(name)
+	}
+	return nil
+}
+
+// registerReserved adds c to the registry under name unless the name is
+// reserved for another agent, releasing the reservation of the same agent.
+// It reports whether the agent reconnected within its grace period.
+func (h *Hub) registerReserved(c *client.Client, name, identity string) (bool, error) {
+	h.reservationsMu.Lock()
+	defer h.reservationsMu.Unlock()
+	res, ok := h.reservations[name]
+	if ok && res.identity != identity {
+		return false, errNameReserved(name)
+	}
+	if err := h.ClientRegistry.Register(c, name); err != nil {
+		return false, err
+	}
+	if ok {
+		res.timer.Stop()
+		delete(h.reservations, name)
+		close(res.released)
+	}
+	return ok, nil
+}
+
+// unregisterReserved removes name from the registry, reserving it
+// for the agent during the grace period, which is returned.
+func (h *Hub) unregisterReserved(name, identity string) time.Duration {
+	h.reservationsMu.Lock()
+	defer h.reservationsMu.Unlock()
+	h.ClientRegistry.Unregister(name)
+
+	grace := h.currentSettings().reconnectGracePeriod
+	if grace == 0 || identity == "" || h.isClosing() {
+		return 0
+	}
+	res := &reservation{identity: identity, released: make(chan struct{})}
+	res.timer = time.AfterFunc(grace, func() {
+		h.reservationsMu.Lock()
+		defer h.reservationsMu.Unlock()
+		if h.reservations[name] != res {
+			return
+		}
+		delete(h.reservations, name)
+		close(res.released)
+		h.activityFeed.Send(fmt.Sprintf("client %v did not reconnect within %v, name released", name, grace))
+	})
+	h.reservations[name] = res
+	return grace
+}
+
+// waitForClient returns the client registered with name. If the name is
+// reserved, it waits for the agent to reconnect until ctx is done,
+// returning an Unavailable error.
+func (h *Hub) waitForClient(ctx context.Context, name string) (*client.Client, error) {
+	for {
+		// The registry is changed under the same lock by registerReserved
+		// and unregisterReserved, so that an agent disconnecting between
+		// both lookups cannot be mistaken for an unknown one.
+		h.reservationsMu.Lock()
+		res := h.reservations[name]
+		c, err := h.ClientRegistry.Get(name)
+		h.reservationsMu.Unlock()
+		if err == nil {
+			return c, nil
+		}
+		if res == nil {
+			return nil, errClientNotFound(name)
+		}
+		select {
+		case <-res.released:
+		case <-h.closingCh:
+			return nil, errHubShuttingDown()
+		case <-ctx.Done():
+			return nil, errClientUnavailable(name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/hubclient"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCallWaitsForReconnectingAgent(t *testing.T) {
+	h := startTestHub(t, WithReconnectGracePeriod(5*time.Second))
+	defer h.Close()
+
+	c, err := NewConnector("ws://"+h.httpListenAddr+"/ws", false, "testserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serveTestAgent(t, h, c, "testserver")
+
+	conn, err := hubclient.Dial(h.grpcListenAddr, "testserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Disconnect the agent, its name being reserved.
+	srv.Stop()
+	waitFor(t, "name reservation", func() bool {
+		h.reservationsMu.Lock()
+		defer h.reservationsMu.Unlock()
+		_, err := h.ClientRegistry.Get("testserver")
+		return h.reservations["testserver"] != nil && err != nil
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var resp wrapperspb.BytesValue
+		err := conn.Invoke(ctx, "/external.Test/Reverse", &wrapperspb.BytesValue{Value: []byte("abc")}, &resp)
+		if err == nil && !bytes.Equal(resp.Value, []byte("cba")) {
+			t.Errorf("response: got %q, want %q", resp.Value, "cba")
+		}
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("call returned while the agent was disconnected: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	srv = serveTestAgent(t, h, c, "testserver")
+	defer srv.Stop()
+	if err := <-result; err != nil {
+		t.Fatalf("call after the agent reconnected: %v", err)
+	}
+}
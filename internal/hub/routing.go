@@ -0,0 +1,390 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/client"
+	"github.com/mwitkow/grpc-proxy/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// Targets of the routes, selecting where their calls are sent.
+const (
+	// TargetLocal calls are served by the hub services.
+	TargetLocal = "local"
+
+	// TargetMetadata calls are proxied to the agent named
+	// by the "name" key of the call metadata, or by the call
+	// authority if WithAuthorityPattern is set.
+	TargetMetadata = "metadata"
+
+	// TargetAgent calls are proxied to the agent named by Route.Agent.
+	TargetAgent = "agent"
+
+	// TargetLabels calls are proxied to one of the agents whose labels
+	// include Route.Labels, in turn.
+	TargetLabels = "labels"
+
+	// TargetUpstream calls are proxied to the plaintext gRPC server
+	// at Route.Upstream.
+	TargetUpstream = "upstream"
+)
+
+// Route maps the calls whose full method name matches Method to a target.
+type Route struct {
+	// Method is a full method name pattern, such as "/pkg.Service/Method",
+	// where * matches any sequence of characters.
+	Method string
+
+	// Target is one of the Target* constants.
+	Target string
+
+	Agent    string
+	Labels   map[string]string
+	Upstream string
+}
+
+// defaultRoutes serves the hub services locally and proxies the
+// external package and the server reflection service to the agent
+// named in the metadata.
+var defaultRoutes = []Route{
+	{Method: "/internal.*", Target: TargetLocal},
+	{Method: "/external.*", Target: TargetMetadata},
+	{Method: "/grpc.reflection.*", Target: TargetMetadata},
+}
+
+// ParseRoute parses a route in the form of "method=target[:argument]",
+// the argument being the agent name, the comma-separated key=value labels
+// or the upstream address of the target. For example:
+//
+//	/billing.*=agent:billing-1
+//	/search.*=labels:region=eu,tier=gold
+//	/legacy.Service/*=upstream:legacy:9000
+func ParseRoute(s string) (Route, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return Route{}, fmt.Errorf("invalid route %q: missing target", s)
+	}
+	r := Route{Method: s[:i], Target: s[i+1:]}
+	var arg string
+	if j := strings.Index(r.Target, ":"); j >= 0 {
+		r.Target, arg = r.Target[:j], r.Target[j+1:]
+	}
+	switch r.Target {
+	case TargetAgent:
+		r.Agent = arg
+	case TargetLabels:
+		r.Labels = make(map[string]string)
+		for _, kv := range strings.Split(arg, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				return Route{}, fmt.Errorf("invalid route %q: labels must be key=value pairs", s)
+			}
+			r.Labels[parts[0]] = parts[1]
+		}
+	case TargetUpstream:
+		r.Upstream = arg
+	default:
+		if arg != "" {
+			return Route{}, fmt.Errorf("invalid route %q: target %v takes no argument", s, r.Target)
+		}
+	}
+	return r, r.validate()
+}
+
+func (r Route) validate() error {
+	if !strings.HasPrefix(r.Method, "/") && !strings.HasPrefix(r.Method, "*") {
+		return fmt.Errorf("invalid route method %q: must start with / or *", r.Method)
+	}
+	switch r.Target {
+	case TargetLocal, TargetMetadata:
+	case TargetAgent:
+		if r.Agent == "" {
+			return fmt.Errorf("route %v: agent name is empty", r.Method)
+		}
+	case TargetLabels:
+		if len(r.Labels) == 0 {
+			return fmt.Errorf("route %v: labels are empty", r.Method)
+		}
+		for k := range r.Labels {
+			if k == "" {
+				return fmt.Errorf("route %v: label name is empty", r.Method)
+			}
+		}
+	case TargetUpstream:
+		if _, _, err := net.SplitHostPort(r.Upstream); err != nil {
+			return fmt.Errorf("route %v: invalid upstream address: %v", r.Method, err)
+		}
+	default:
+		return fmt.Errorf("route %v: unknown target %q", r.Method, r.Target)
+	}
+	return nil
+}
+
+func (r Route) String() string {
+	switch r.Target {
+	case TargetAgent:
+		return fmt.Sprintf("%v=%v:%v", r.Method, r.Target, r.Agent)
+	case TargetLabels:
+		return fmt.Sprintf("%v=%v:%v", r.Method, r.Target, formatLabels(r.Labels))
+	case TargetUpstream:
+		return fmt.Sprintf("%v=%v:%v", r.Method, r.Target, r.Upstream)
+	}
+	return fmt.Sprintf("%v=%v", r.Method, r.Target)
+}
+
+// WithRoutes sets the routing table of the calls not served by the hub
+// services. The first route matching the full method name of a call is
+// used; calls matching no route are rejected. The default table serves
+// /internal.* locally and proxies /external.* and /grpc.reflection.*
+// using the metadata.
+func WithRoutes(routes ...Route) Option {
+	return func(h *Hub) error {
+		table, err := newRoutingTable(routes)
+		if err != nil {
+			return err
+		}
+		h.settings.routes = table
+		return nil
+	}
+}
+
+// WithAuthorityPattern derives the agent of the calls routed using the
+// metadata from their :authority pseudo-header, so that tools which
+// cannot set metadata can reach agents. The pattern holds a single *
+// matching the agent name, such as "*.hub.local": a call made with the
+// authority testserver.hub.local:9090 is proxied to testserver. The port
+// is ignored and the host is matched case-insensitively. Calls whose
+// authority does not match fall back to the "name" metadata key.
+func WithAuthorityPattern(pattern string) Option {
+	return func(h *Hub) error {
+		p, err := parseAuthorityPattern(pattern)
+		if err != nil {
+			return err
+		}
+		h.settings.authorityPattern = p
+		return nil
+	}
+}
+
+// authorityPattern extracts the agent name from the call authority.
+type authorityPattern struct {
+	pattern        string
+	prefix, suffix string
+}
+
+func parseAuthorityPattern(pattern string) (*authorityPattern, error) {
+	if strings.Count(pattern, "*") != 1 {
+		return nil, fmt.Errorf("invalid authority pattern %q: must hold a single *", pattern)
+	}
+	i := strings.Index(pattern, "*")
+	return &authorityPattern{pattern: pattern, prefix: pattern[:i], suffix: pattern[i+1:]}, nil
+}
+
+// name returns the agent name matched by authority, if any.
+func (p *authorityPattern) name(authority string) (string, bool) {
+	host := authority
+	if h, _, err := net.SplitHostPort(authority); err == nil {
+		host = h
+	}
+	if len(host) <= len(p.prefix)+len(p.suffix) ||
+		!strings.EqualFold(host[:len(p.prefix)], p.prefix) ||
+		!strings.EqualFold(host[len(host)-len(p.suffix):], p.suffix) {
+		return "", false
+	}
+	return host[len(p.prefix) : len(host)-len(p.suffix)], true
+}
+
+// routingTable holds the compiled routes.
+type routingTable []*route
+
+type route struct {
+	Route
+	method *regexp.Regexp
+
+	// next is the index of the agent selected by a labels target.
+	next uint64
+}
+
+func newRoutingTable(routes []Route) (routingTable, error) {
+	var table routingTable
+	for _, r := range routes {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		parts := strings.Split(r.Method, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		method, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return nil, err
+		}
+		table = append(table, &route{Route: r, method: method})
+	}
+	return table, nil
+}
+
+func (t routingTable) match(fullMethodName string) (*route, bool) {
+	for _, r := range t {
+		if r.method.MatchString(fullMethodName) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// director routes the incoming gRPC calls that are not served by the hub
+// services using the routing table.
+func (h *Hub) director(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
+	r, ok := h.currentSettings().routes.match(fullMethodName)
+	if !ok || r.Target == TargetLocal {
+		return nil, nil, grpc.Errorf(codes.Unimplemented, "Unknown method")
+	}
+	if h.isClosing() {
+		return nil, nil, errHubShuttingDown()
+	}
+	if r.Target == TargetUpstream {
+		conn, err := h.upstreamConn(r.Upstream)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.activityFeed.Send(fmt.Sprintf("proxying gRPC request (%v) to upstream: %v", fullMethodName, r.Upstream))
+		return ctx, conn, nil
+	}
+
+	client, err := h.routeClient(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.IsClosed() {
+		// The session is closed but not unregistered yet.
+		return nil, nil, errClientUnavailable(client.Name)
+	}
+	if client.Conn != nil {
+		client.Stats.IncProxiedCalls()
+		h.activityFeed.Send(fmt.Sprintf("proxying gRPC request (%v) to upstream: %v", fullMethodName, client.Name))
+		return ctx, client.Conn, nil
+	}
+	dialOpts := append(h.backendDialOptions(nil),
+		grpc.WithDialer(func(s string, d time.Duration) (net.Conn, error) {
+			return client.Session.Open()
+		}),
+	)
+	conn, err := grpc.DialContext(ctx, fullMethodName, dialOpts...)
+	client.Stats.IncProxiedCalls()
+	h.activityFeed.Send(fmt.Sprintf("proxying gRPC request (%v) to: %v", fullMethodName, client.Name))
+	return ctx, conn, err
+}
+
+// routeClient returns the agent or upstream the calls of r are proxied to.
+func (h *Hub) routeClient(ctx context.Context, r *route) (*client.Client, error) {
+	switch r.Target {
+	case TargetAgent:
+		return h.waitForClient(ctx, r.Agent)
+	case TargetLabels:
+		return h.selectClient(r)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	pattern := h.currentSettings().authorityPattern
+	if pattern != nil {
+		if authority := md[":authority"]; len(authority) > 0 {
+			if name, ok := pattern.name(authority[0]); ok {
+				return h.waitForClient(ctx, name)
+			}
+		}
+	}
+	nameList := md["name"]
+	if len(nameList) == 0 || nameList[0] == "" {
+		return nil, errTargetMissing(pattern)
+	}
+	return h.waitForClient(ctx, nameList[0])
+}
+
+// selectClient returns the next agent whose labels include the ones of r.
+func (h *Hub) selectClient(r *route) (*client.Client, error) {
+	var matches []*client.Client
+	for _, c := range h.ClientRegistry.List() {
+		if hasLabels(c.Labels, r.Labels) && !c.IsClosed() {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errNoClientMatches(formatLabels(r.Labels))
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	n := atomic.AddUint64(&r.next, 1) - 1
+	return matches[n%uint64(len(matches))], nil
+}
+
+func hasLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabels returns the labels as sorted key=value pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// backendDialOptions returns the options of the connections the calls
+// are proxied through, using plaintext if creds is nil.
+func (h *Hub) backendDialOptions(creds credentials.TransportCredentials) []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithCodec(proxy.Codec())}
+	if creds != nil {
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	if h.grpcMaxMessageSize > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(h.grpcMaxMessageSize),
+			grpc.MaxCallSendMsgSize(h.grpcMaxMessageSize),
+		))
+	}
+	return opts
+}
+
+// upstreamConn returns the connection to the upstream at addr,
+// shared by the calls routed to it.
+func (h *Hub) upstreamConn(addr string) (*grpc.ClientConn, error) {
+	h.upstreamsMu.Lock()
+	defer h.upstreamsMu.Unlock()
+	if conn, ok := h.upstreams[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(addr, h.backendDialOptions(nil)...)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Unavailable, "upstream %v: %v", addr, err)
+	}
+	h.upstreams[addr] = conn
+	return conn, nil
+}
+
+func (h *Hub) closeUpstreams() {
+	h.upstreamsMu.Lock()
+	defer h.upstreamsMu.Unlock()
+	for addr, conn := range h.upstreams {
+		conn.Close()
+		delete(h.upstreams, addr)
+	}
+}
@@ -0,0 +1,253 @@
+package hub
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/devodev/grpc-demo/internal/client"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Route
+	}{
+		{"/internal.*=local", Route{Method: "/internal.*", Target: TargetLocal}},
+		{"/external.*=metadata", Route{Method: "/external.*", Target: TargetMetadata}},
+		{"*=metadata", Route{Method: "*", Target: TargetMetadata}},
+		{"/billing.*=agent:billing-1", Route{Method: "/billing.*", Target: TargetAgent, Agent: "billing-1"}},
+		{"/search.*=labels:region=eu,tier=gold", Route{Method: "/search.*", Target: TargetLabels, Labels: map[string]string{"region": "eu", "tier": "gold"}}},
+		{"/search.*=labels:env=a=b", Route{Method: "/search.*", Target: TargetLabels, Labels: map[string]string{"env": "a=b"}}},
+		{"/legacy.Service/*=upstream:legacy:9000", Route{Method: "/legacy.Service/*", Target: TargetUpstream, Upstream: "legacy:9000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRoute(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.in {
+				t.Fatalf("String: got %q, want %q", s, tt.in)
+			}
+		})
+	}
+}
+
+func TestParseRouteInvalid(t *testing.T) {
+	for _, in := range []string{
+		"/external.*",
+		"external.*=metadata",
+		"/external.*=",
+		"/external.*=unknown",
+		"/external.*=metadata:testserver",
+		"/external.*=local:testserver",
+		"/billing.*=agent",
+		"/billing.*=agent:",
+		"/search.*=labels",
+		"/search.*=labels:region",
+		"/search.*=labels:=eu",
+		"/search.*=labels:region=eu,",
+		"/legacy.*=upstream",
+		"/legacy.*=upstream:legacy",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if r, err := ParseRoute(in); err == nil {
+				t.Fatalf("got %+v, want an error", r)
+			}
+		})
+	}
+}
+
+func TestRoutingTableMatch(t *testing.T) {
+	table, err := newRoutingTable([]Route{
+		{Method: "/billing.Billing/Charge", Target: TargetAgent, Agent: "billing-1"},
+		{Method: "/billing.*", Target: TargetAgent, Agent: "billing-2"},
+		{Method: "/search.*/Query", Target: TargetLabels, Labels: map[string]string{"region": "eu"}},
+		{Method: "/internal.*", Target: TargetLocal},
+		{Method: "*", Target: TargetMetadata},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		want   int
+	}{
+		// The first matching route wins.
+		{"/billing.Billing/Charge", 0},
+		{"/billing.Billing/Refund", 1},
+		{"/billing.v2.Billing/Charge", 1},
+		{"/search.Search/Query", 2},
+		{"/search.v1.Search/Query", 2},
+		{"/internal.Hub/List", 3},
+		// Dots are literal and patterns are anchored.
+		{"/billingX/Charge", 4},
+		{"/search.Search/QueryAll", 4},
+		{"/other.internal.Hub/List", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			r, ok := table.match(tt.method)
+			if !ok {
+				t.Fatal("no route matched")
+			}
+			if r != table[tt.want] {
+				t.Fatalf("got route %v, want %v", r.Route, table[tt.want].Route)
+			}
+		})
+	}
+
+	table, err = newRoutingTable(defaultRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := table.match("/grpc.testing.TestService/UnaryCall"); ok {
+		t.Fatalf("default routes: got route %v, want none", r.Route)
+	}
+}
+
+func TestRoutingTableInvalid(t *testing.T) {
+	if _, err := newRoutingTable([]Route{{Method: "/billing.*", Target: TargetAgent}}); err == nil {
+		t.Fatal("route without agent: got no error")
+	}
+}
+
+// newRoutingHub returns a hub whose registry holds upstream clients
+// with the provided names and labels.
+func newRoutingHub(t *testing.T, clients map[string]map[string]string) *Hub {
+	t.Helper()
+	h := defaultHub()
+	h.ClientRegistry = client.NewRegistryMem()
+	for name, labels := range clients {
+		c, err := client.NewUpstream(name, name+":9000", nil, client.WithLabels(labels))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.ClientRegistry.Register(c, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func TestSelectClientRoundRobin(t *testing.T) {
+	h := newRoutingHub(t, map[string]map[string]string{
+		"eu-1": {"region": "eu"},
+		"eu-2": {"region": "eu", "tier": "gold"},
+		"us-1": {"region": "us", "tier": "gold"},
+	})
+
+	tests := []struct {
+		labels map[string]string
+		want   []string
+	}{
+		{map[string]string{"region": "eu"}, []string{"eu-1", "eu-2", "eu-1", "eu-2"}},
+		{map[string]string{"tier": "gold"}, []string{"eu-2", "us-1", "eu-2"}},
+		{map[string]string{"region": "us", "tier": "gold"}, []string{"us-1", "us-1"}},
+	}
+	for _, tt := range tests {
+		r := &route{Route: Route{Method: "*", Target: TargetLabels, Labels: tt.labels}}
+		for i, want := range tt.want {
+			c, err := h.selectClient(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Name != want {
+				t.Fatalf("labels %v, call %d: got %v, want %v", tt.labels, i, c.Name, want)
+			}
+		}
+	}
+
+	r := &route{Route: Route{Method: "*", Target: TargetLabels, Labels: map[string]string{"region": "asia"}}}
+	if _, err := h.selectClient(r); status.Code(err) != codes.Unavailable {
+		t.Fatalf("no matching client: got %v, want code %v", err, codes.Unavailable)
+	}
+}
+
+func TestParseAuthorityPattern(t *testing.T) {
+	for _, pattern := range []string{"", "hub.local", "*.*.hub.local", "**.hub.local"} {
+		if _, err := parseAuthorityPattern(pattern); err == nil {
+			t.Errorf("pattern %q: got no error", pattern)
+		}
+	}
+	if _, err := parseAuthorityPattern("*.hub.local"); err != nil {
+		t.Errorf("pattern %q: %v", "*.hub.local", err)
+	}
+}
+
+func TestAuthorityPatternName(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		authority string
+		want      string // empty if the authority does not match
+	}{
+		{"*.hub.local", "testserver.hub.local:9090", "testserver"},
+		{"*.hub.local", "testserver.hub.local", "testserver"},
+		{"*.hub.local", "TestServer.HUB.Local:9090", "TestServer"},
+		{"*.hub.local", "a.b.hub.local:9090", "a.b"},
+		{"*.hub.local", ".hub.local:9090", ""},
+		{"*.hub.local", "hub.local:9090", ""},
+		{"*.hub.local", "testserver.other.local:9090", ""},
+		{"*.hub.local", "localhost:9090", ""},
+		{"agent-*", "agent-testserver:9090", "testserver"},
+		{"agent-*", "testserver:9090", ""},
+	}
+	for _, tt := range tests {
+		p, err := parseAuthorityPattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, ok := p.name(tt.authority)
+		if ok != (tt.want != "") || name != tt.want {
+			t.Errorf("pattern %q, authority %q: got (%q, %v), want %q", tt.pattern, tt.authority, name, ok, tt.want)
+		}
+	}
+}
+
+func TestRouteClientAuthority(t *testing.T) {
+	h := newRoutingHub(t, map[string]map[string]string{"testserver": nil, "other": nil})
+	r := &route{Route: Route{Method: "*", Target: TargetMetadata}}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		md       metadata.MD
+		want     string
+		wantCode codes.Code
+	}{
+		{"authority", "*.hub.local", metadata.Pairs(":authority", "testserver.hub.local:9090"), "testserver", codes.OK},
+		{"authority over metadata", "*.hub.local", metadata.Pairs(":authority", "testserver.hub.local:9090", "name", "other"), "testserver", codes.OK},
+		{"metadata fallback", "*.hub.local", metadata.Pairs(":authority", "localhost:9090", "name", "other"), "other", codes.OK},
+		{"no target", "*.hub.local", metadata.Pairs(":authority", "localhost:9090"), "", codes.InvalidArgument},
+		{"unknown agent", "*.hub.local", metadata.Pairs(":authority", "missing.hub.local:9090", "name", "other"), "", codes.NotFound},
+		{"no pattern", "", metadata.Pairs(":authority", "testserver.hub.local:9090", "name", "other"), "other", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.settings.authorityPattern = nil
+			if tt.pattern != "" {
+				p, err := parseAuthorityPattern(tt.pattern)
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.settings.authorityPattern = p
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			c, err := h.routeClient(ctx, r)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got %v, want code %v", err, tt.wantCode)
+			}
+			if err == nil && c.Name != tt.want {
+				t.Fatalf("got client %v, want %v", c.Name, tt.want)
+			}
+		})
+	}
+}
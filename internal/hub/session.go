@@ -0,0 +1,98 @@
+package hub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/yamux"
+)
+
+// SessionConfig tunes the yamux sessions multiplexing the streams
+// exchanged with remote servers and callers.
+// Zero values keep the yamux defaults.
+type SessionConfig struct {
+	// AcceptBacklog limits the number of streams waiting to be accepted.
+	AcceptBacklog int
+
+	// KeepAliveInterval is the period of the keep-alive pings.
+	KeepAliveInterval time.Duration
+
+	// DisableKeepAlive disables the keep-alive pings.
+	DisableKeepAlive bool
+
+	// ConnectionWriteTimeout is the time after which a blocked write
+	// closes the session.
+	ConnectionWriteTimeout time.Duration
+
+	// MaxStreamWindowSize is the maximum receive window of a stream,
+	// in bytes. Larger windows help throughput on high-latency links.
+	MaxStreamWindowSize uint32
+}
+
+// yamuxConfig returns the yamux configuration matching c.
+func (c SessionConfig) yamuxConfig() (*yamux.Config, error) {
+	if c.AcceptBacklog < 0 {
+		return nil, fmt.Errorf("session accept backlog must be positive")
+	}
+	if c.KeepAliveInterval < 0 {
+		return nil, fmt.Errorf("session keep-alive interval must be positive")
+	}
+	if c.ConnectionWriteTimeout < 0 {
+		return nil, fmt.Errorf("session write timeout must be positive")
+	}
+	cfg := yamux.DefaultConfig()
+	if c.AcceptBacklog > 0 {
+		cfg.AcceptBacklog = c.AcceptBacklog
+	}
+	if c.KeepAliveInterval > 0 {
+		cfg.KeepAliveInterval = c.KeepAliveInterval
+	}
+	if c.DisableKeepAlive {
+		cfg.EnableKeepAlive = false
+	}
+	if c.ConnectionWriteTimeout > 0 {
+		cfg.ConnectionWriteTimeout = c.ConnectionWriteTimeout
+	}
+	if c.MaxStreamWindowSize > 0 {
+		cfg.MaxStreamWindowSize = c.MaxStreamWindowSize
+	}
+	if err := yamux.VerifyConfig(cfg); err != nil {
+		return nil, fmt.Errorf("session config: %v", err)
+	}
+	return cfg, nil
+}
+
+// WithSessionConfig sets the configuration of the yamux sessions
+// established with remote servers and callers.
+func WithSessionConfig(c SessionConfig) Option {
+	return func(h *Hub) error {
+		cfg, err := c.yamuxConfig()
+		if err != nil {
+			return err
+		}
+		h.settings.sessionConfig = cfg
+		return nil
+	}
+}
+
+// WithConnectorSessionConfig sets the configuration of the yamux session
+// established with the hub.
+func WithConnectorSessionConfig(c SessionConfig) ConnectorOption {
+	return func(conn *Connector) error {
+		cfg, err := c.yamuxConfig()
+		if err != nil {
+			return err
+		}
+		conn.sessionConfig = cfg
+		return nil
+	}
+}
+
+func describeSessionConfig(c *yamux.Config) string {
+	keepAlive := "disabled"
+	if c.EnableKeepAlive {
+		keepAlive = c.KeepAliveInterval.String()
+	}
+	return fmt.Sprintf("accept backlog: %v, keep-alive: %v, write timeout: %v, max stream window: %v",
+		c.AcceptBacklog, keepAlive, c.ConnectionWriteTimeout, c.MaxStreamWindowSize)
+}
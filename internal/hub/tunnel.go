@@ -0,0 +1,159 @@
+package hub
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/transport"
+
+	"golang.org/x/net/http2"
+)
+
+var defaultTunnelHandshakeTimeout = 10 * time.Second
+
+// WithTunnelListenAddr sets the listening address of the tunnel listener.
+//
+// The tunnel listener accepts remote servers registering over raw TLS
+// (tls://) or HTTP/2 CONNECT (h2://), avoiding the websocket framing
+// overhead. It requires a TLS configuration.
+func WithTunnelListenAddr(a string) Option {
+	return func(h *Hub) error {
+		h.tunnelListenAddr = a
+		return nil
+	}
+}
+
+func (h *Hub) listenAndServeTunnel() {
+	tlsConfig := h.serverTLSConfig().Clone()
+	tlsConfig.NextProtos = []string{transport.ALPNProto, http2.NextProtoTLS}
+
+	l, err := tls.Listen("tcp", h.tunnelListenAddr, tlsConfig)
+	if err != nil {
+		h.logger.Fatalf("failed to listen: %v", err)
+		return
+	}
+
+	go func() {
+		<-h.closingCh
+
+		h.logger.Println("tunnel listener is shutting down..")
+		l.Close()
+	}()
+
+	h2Server := &http2.Server{}
+
+	h.logger.Printf("tunnel listener listening on: %v", h.tunnelListenAddr)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-h.closingCh:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			h.logger.Errorf("tunnel listener accept error: %v", err)
+			return
+		}
+		go func() {
+			tlsConn := conn.(*tls.Conn)
+			tlsConn.SetDeadline(time.Now().Add(defaultTunnelHandshakeTimeout))
+			if err := tlsConn.Handshake(); err != nil {
+				h.logger.Errorf("tunnel handshake: %v", err)
+				conn.Close()
+				return
+			}
+			tlsConn.SetDeadline(time.Time{})
+
+			state := tlsConn.ConnectionState()
+			switch state.NegotiatedProtocol {
+			case http2.NextProtoTLS:
+				h2Server.ServeConn(tlsConn, &http2.ServeConnOpts{
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if r.TLS == nil {
+							r.TLS = &state
+						}
+						h.handleH2Connect(w, r)
+					}),
+				})
+			default:
+				h.handleTLSTunnel(tlsConn)
+			}
+		}()
+	}
+}
+
+// handleTLSTunnel reads the framed handshake of a remote server
+// registering over raw TLS.
+func (h *Hub) handleTLSTunnel(conn *tls.Conn) {
+	conn.SetDeadline(time.Now().Add(defaultTunnelHandshakeTimeout))
+	header, err := transport.ReadHandshake(conn)
+	if err != nil {
+		h.logger.Errorln(err)
+		conn.Close()
+		return
+	}
+	state := conn.ConnectionState()
+	reg := registration{
+		header:     header,
+		remoteAddr: conn.RemoteAddr().String(),
+		userAgent:  header.Get("User-Agent"),
+		tls:        &state,
+	}
+	if err := h.checkRegistration(reg); err != nil {
+		transport.WriteAck(conn, err)
+		h.logger.Errorln(err)
+		conn.Close()
+		return
+	}
+	if err := transport.WriteAck(conn, nil); err != nil {
+		h.logger.Errorln(err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	h.register(conn, reg)
+}
+
+// handleH2Connect serves remote servers registering over HTTP/2 CONNECT.
+// The stream stays open for as long as the client session lives.
+func (h *Hub) handleH2Connect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		w.Header().Set("Allow", http.MethodConnect)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reg := registration{
+		header:     r.Header,
+		remoteAddr: r.RemoteAddr,
+		userAgent:  r.UserAgent(),
+		tls:        r.TLS,
+	}
+	if err := h.checkRegistration(reg); err != nil {
+		h.logger.Errorln(err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	conn, err := transport.NewH2ServerConn(w, r)
+	if err != nil {
+		h.logger.Errorln(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.register(conn, reg)
+	conn.Wait()
+}
+
+func (h *Hub) validateTunnel() error {
+	if h.tunnelListenAddr != "" && h.httpTLSConfig == nil {
+		return fmt.Errorf("tunnel listener requires a TLS configuration")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package hub
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/certs"
+	"github.com/devodev/grpc-demo/internal/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Upstream is a gRPC server dialed by the hub, registered alongside
+// the agents so that callers reach it using its name.
+type Upstream struct {
+	Name    string
+	Address string
+	Labels  map[string]string
+
+	// TLS is nil for plaintext connections.
+	TLS *UpstreamTLS
+}
+
+// UpstreamTLS holds the TLS settings of an upstream connection.
+// The files are optional and reloaded when they change.
+type UpstreamTLS struct {
+	// CACertFile verifies the upstream instead of the system roots.
+	CACertFile string
+
+	// CertFile and KeyFile hold the client certificate
+	// presented to the upstream.
+	CertFile string
+	KeyFile  string
+
+	// ServerName defaults to the host of the address.
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// WithUpstreams registers gRPC servers dialed by the hub in the client
+// registry. Their names cannot be used by agents.
+func WithUpstreams(upstreams ...Upstream) Option {
+	return func(h *Hub) error {
+		for _, u := range upstreams {
+			if err := u.validate(); err != nil {
+				return err
+			}
+			for _, o := range h.upstreamBackends {
+				if o.Name == u.Name {
+					return fmt.Errorf("upstream %v: duplicate name", u.Name)
+				}
+			}
+			h.upstreamBackends = append(h.upstreamBackends, u)
+		}
+		return nil
+	}
+}
+
+func (u Upstream) validate() error {
+	if u.Name == "" {
+		return fmt.Errorf("upstream name is empty")
+	}
+	if _, _, err := net.SplitHostPort(u.Address); err != nil {
+		return fmt.Errorf("upstream %v: invalid address: %v", u.Name, err)
+	}
+	// Load the TLS files now, so that Validate reports them.
+	if u.TLS != nil {
+		if _, err := u.TLS.config(u.Address); err != nil {
+			return fmt.Errorf("upstream %v: %v", u.Name, err)
+		}
+	}
+	return nil
+}
+
+// registerUpstreams dials the upstreams and registers them.
+// The connections are established in the background.
+func (h *Hub) registerUpstreams() error {
+	for _, u := range h.upstreamBackends {
+		var creds credentials.TransportCredentials
+		if u.TLS != nil {
+			tlsConfig, err := u.TLS.config(u.Address)
+			if err != nil {
+				return fmt.Errorf("upstream %v: %v", u.Name, err)
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		stats := &client.Stats{}
+		opts := append(h.backendDialOptions(creds),
+			grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+				conn, err := net.DialTimeout("tcp", addr, timeout)
+				if err != nil {
+					return nil, err
+				}
+				return stats.Meter(conn), nil
+			}),
+		)
+		conn, err := grpc.Dial(u.Address, opts...)
+		if err != nil {
+			return fmt.Errorf("upstream %v: %v", u.Name, err)
+		}
+		c, err := client.NewUpstream(u.Name, u.Address, conn, client.WithLabels(u.Labels), client.WithStats(stats))
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if err := h.ClientRegistry.Register(c, u.Name); err != nil {
+			conn.Close()
+			return err
+		}
+		h.logger.Printf("registered upstream with name: %v (%v)", u.Name, u.Address)
+	}
+	return nil
+}
+
+func (t *UpstreamTLS) config(address string) (*tls.Config, error) {
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(address)
+	}
+	base := &tls.Config{ServerName: serverName, InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CACertFile == "" && t.CertFile == "" && t.KeyFile == "" {
+		return base, nil
+	}
+	reloader, err := certs.NewReloader(t.CertFile, t.KeyFile, t.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	return reloader.ClientConfig(base, serverName), nil
+}
+
+// closeUpstreamClients closes the connections of the registered upstreams.
+func (h *Hub) closeUpstreamClients() {
+	for _, c := range h.ClientRegistry.List() {
+		if c.Conn != nil {
+			c.Conn.Close()
+		}
+	}
+}
+
+func upstreamsEqual(a, b []Upstream) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,25 @@
+package hub
+
+import "testing"
+
+func TestUpstreamValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		u       Upstream
+		wantErr bool
+	}{
+		{"plaintext", Upstream{Name: "inventory", Address: "localhost:9000"}, false},
+		{"tls without files", Upstream{Name: "inventory", Address: "localhost:9000", TLS: &UpstreamTLS{}}, false},
+		{"empty name", Upstream{Address: "localhost:9000"}, true},
+		{"invalid address", Upstream{Name: "inventory", Address: "localhost"}, true},
+		{"missing ca file", Upstream{Name: "inventory", Address: "localhost:9000", TLS: &UpstreamTLS{CACertFile: "testdata/missing.pem"}}, true},
+		{"missing key file", Upstream{Name: "inventory", Address: "localhost:9000", TLS: &UpstreamTLS{CertFile: "testdata/missing.pem"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.u.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
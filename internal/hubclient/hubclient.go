@@ -0,0 +1,244 @@
+// Package hubclient dials the hub on behalf of Go callers.
+//
+// The calls of the connections returned by Dial are routed by the hub to
+// the registered client they are dialed for, so that generated clients
+// such as pb.NewFluentdClient can be used unchanged:
+//
+//	conn, err := hubclient.Dial("localhost:9090", "testserver")
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	resp, err := pb.NewFluentdClient(conn).Start(ctx, &pb.FluentdStartRequest{})
+//
+// Dial resolves the "hub:///<name>" target using the resolver returned by
+// NewBuilder. Callers dialing the hub themselves can use it along with the
+// interceptors:
+//
+//	md := hubclient.Target("testserver")
+//	conn, err := grpc.Dial("hub:///testserver",
+//		grpc.WithResolvers(hubclient.NewBuilder("localhost:9090")),
+//		grpc.WithChainUnaryInterceptor(hubclient.UnaryClientInterceptor(md)),
+//		grpc.WithChainStreamInterceptor(hubclient.StreamClientInterceptor(md)),
+//		grpc.WithInsecure(),
+//	)
+package hubclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+)
+
+// TargetKey is the metadata key naming the client a call is routed to.
+const TargetKey = "name"
+
+// Option configures the connections returned by Dial.
+type Option func(*options) error
+
+type options struct {
+	tlsConfig    *tls.Config
+	authType     string
+	authToken    string
+	insecureAuth bool
+	metadata     metadata.MD
+	dialOptions  []grpc.DialOption
+}
+
+// WithTLSConfig dials the hub using TLS. Connections are plaintext by default.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) error {
+		if c == nil {
+			return fmt.Errorf("tls config must be non-nil")
+		}
+		o.tlsConfig = c
+		return nil
+	}
+}
+
+// WithAuthToken sends token in the authorization metadata of the calls,
+// using the tokenType scheme such as "Bearer". The token is sent as
+// per-RPC credentials, which require WithTLSConfig unless WithInsecureAuth
+// is used as well.
+func WithAuthToken(tokenType, token string) Option {
+	return func(o *options) error {
+		if token == "" {
+			return fmt.Errorf("auth token is empty")
+		}
+		o.authType = tokenType
+		o.authToken = token
+		return nil
+	}
+}
+
+// WithInsecureAuth lets the auth token be sent over plaintext connections.
+// INSECURE: anyone able to observe the traffic can reuse the token.
+func WithInsecureAuth() Option {
+	return func(o *options) error {
+		o.insecureAuth = true
+		return nil
+	}
+}
+
+// WithMetadata adds md to the metadata of the calls.
+func WithMetadata(md metadata.MD) Option {
+	return func(o *options) error {
+		for k, v := range md {
+			o.metadata.Append(k, v...)
+		}
+		return nil
+	}
+}
+
+// WithDialOptions appends opts to the options used to dial the hub,
+// such as grpc.WithBlock or additional interceptors.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) error {
+		o.dialOptions = append(o.dialOptions, opts...)
+		return nil
+	}
+}
+
+// Dial returns a connection to the hub gRPC server at hubAddr
+// whose calls are routed to the client registered as name.
+func Dial(hubAddr, name string, opts ...Option) (*grpc.ClientConn, error) {
+	return DialContext(context.Background(), hubAddr, name, opts...)
+}
+
+// DialContext is like Dial, ctx bounding a blocking dial.
+func DialContext(ctx context.Context, hubAddr, name string, opts ...Option) (*grpc.ClientConn, error) {
+	if name == "" {
+		return nil, fmt.Errorf("client name is empty")
+	}
+	o := &options{metadata: metadata.MD{}}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	o.metadata.Set(TargetKey, name)
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithResolvers(NewBuilder(hubAddr)),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(o.metadata)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(o.metadata)),
+	}
+	if o.authToken != "" {
+		if o.tlsConfig == nil && !o.insecureAuth {
+			return nil, fmt.Errorf("auth token requires a tls config or insecure auth")
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(TokenCredentials(o.authType, o.authToken, o.insecureAuth)))
+	}
+	if o.tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	dialOpts = append(dialOpts, o.dialOptions...)
+	return grpc.DialContext(ctx, Scheme+":///"+name, dialOpts...)
+}
+
+// Scheme is the scheme of the targets resolved by the resolver returned by
+// NewBuilder, such as "hub:///testserver".
+const Scheme = "hub"
+
+// NewBuilder returns a resolver builder resolving the "hub:///<name>"
+// targets to the hub gRPC server at hubAddr, which is also used as the
+// authority of the connections. The calls are routed to name only when
+// made with the interceptors adding its Target metadata.
+func NewBuilder(hubAddr string) resolver.Builder {
+	return hubResolverBuilder{hubAddr: hubAddr}
+}
+
+type hubResolverBuilder struct {
+	hubAddr string
+}
+
+func (b hubResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	if target.Endpoint == "" {
+		return nil, fmt.Errorf("hub target names no client: use %s:///<name>", Scheme)
+	}
+	cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: b.hubAddr, ServerName: b.hubAddr}}})
+	return hubResolver{}, nil
+}
+
+func (b hubResolverBuilder) Scheme() string {
+	return Scheme
+}
+
+// hubResolver resolves to a static address, nothing to refresh or release.
+type hubResolver struct{}
+
+func (hubResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (hubResolver) Close() {}
+
+// TokenCredentials returns per-RPC credentials sending token in the
+// authorization metadata of the calls, using the tokenType scheme which
+// defaults to "Bearer". They require transport security unless insecure
+// is set.
+func TokenCredentials(tokenType, token string, insecure bool) credentials.PerRPCCredentials {
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	return tokenCredentials{authorization: tokenType + " " + token, insecure: insecure}
+}
+
+type tokenCredentials struct {
+	authorization string
+	insecure      bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": c.authorization}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return !c.insecure
+}
+
+// Target returns the metadata routing the calls to the client registered
+// as name, to be used with the interceptors by callers dialing the hub
+// themselves.
+func Target(name string) metadata.MD {
+	return metadata.Pairs(TargetKey, name)
+}
+
+// UnaryClientInterceptor adds md to the outgoing metadata of the unary
+// calls. Keys already set by the caller are left untouched, so that
+// a call can be routed to another client using its context.
+func UnaryClientInterceptor(md metadata.MD) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withMetadata(ctx, md), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is like UnaryClientInterceptor for streaming calls.
+func StreamClientInterceptor(md metadata.MD) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withMetadata(ctx, md), desc, cc, method, opts...)
+	}
+}
+
+// withMetadata returns ctx with the keys of md missing from its outgoing metadata.
+func withMetadata(ctx context.Context, md metadata.MD) context.Context {
+	out, _ := metadata.FromOutgoingContext(ctx)
+	var pairs []string
+	for k, values := range md {
+		if len(out.Get(k)) > 0 {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, k, v)
+		}
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
@@ -0,0 +1,118 @@
+package hubclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// startTestServer serves any method on a bufconn listener, sending back
+// the incoming metadata of each call on md.
+func startTestServer(t *testing.T, md chan<- metadata.MD) (*grpc.Server, *bufconn.Listener) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		in, _ := metadata.FromIncomingContext(stream.Context())
+		md <- in
+		var m emptypb.Empty
+		if err := stream.RecvMsg(&m); err != nil {
+			return err
+		}
+		return stream.SendMsg(&m)
+	}))
+	go srv.Serve(lis)
+	return srv, lis
+}
+
+func dialTestServer(t *testing.T, lis *bufconn.Listener, name string, opts ...Option) *grpc.ClientConn {
+	t.Helper()
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		if addr != "hub.test:9090" {
+			t.Errorf("dialed address: got %q, want %q", addr, "hub.test:9090")
+		}
+		return lis.Dial()
+	}
+	opts = append(opts, WithDialOptions(grpc.WithContextDialer(dialer)))
+	conn, err := Dial("hub.test:9090", name, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestDialInjectsMetadata(t *testing.T) {
+	md := make(chan metadata.MD, 1)
+	srv, lis := startTestServer(t, md)
+	defer srv.Stop()
+	conn := dialTestServer(t, lis, "testserver",
+		WithAuthToken("", "secret"),
+		WithInsecureAuth(),
+		WithMetadata(metadata.Pairs("env", "dev")),
+	)
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantName string
+		wantEnv  string
+	}{
+		{"defaults", context.Background(), "testserver", "dev"},
+		{"caller keys", metadata.AppendToOutgoingContext(context.Background(), TargetKey, "other", "env", "prod"), "other", "prod"},
+	}
+	for _, tt := range tests {
+		calls := map[string]func(ctx context.Context) error{
+			"unary": func(ctx context.Context) error {
+				return conn.Invoke(ctx, "/test.Service/Unary", &emptypb.Empty{}, &emptypb.Empty{})
+			},
+			"stream": func(ctx context.Context) error {
+				desc := &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}
+				stream, err := conn.NewStream(ctx, desc, "/test.Service/Stream")
+				if err != nil {
+					return err
+				}
+				if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+					return err
+				}
+				if err := stream.CloseSend(); err != nil {
+					return err
+				}
+				return stream.RecvMsg(&emptypb.Empty{})
+			},
+		}
+		for kind, call := range calls {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				if err := call(tt.ctx); err != nil {
+					t.Fatal(err)
+				}
+				got := <-md
+				if v := got.Get(TargetKey); len(v) != 1 || v[0] != tt.wantName {
+					t.Errorf("%s: got %q, want %q", TargetKey, v, tt.wantName)
+				}
+				if v := got.Get("env"); len(v) != 1 || v[0] != tt.wantEnv {
+					t.Errorf("env: got %q, want %q", v, tt.wantEnv)
+				}
+				if v := got.Get("authorization"); len(v) != 1 || v[0] != "Bearer secret" {
+					t.Errorf("authorization: got %q, want %q", v, "Bearer secret")
+				}
+			})
+		}
+	}
+}
+
+func TestDialAuthTokenRequiresTLS(t *testing.T) {
+	if _, err := Dial("hub.test:9090", "testserver", WithAuthToken("Bearer", "secret")); err == nil {
+		t.Fatal("dial with an auth token over plaintext: got no error")
+	}
+}
+
+func TestDialRequiresName(t *testing.T) {
+	if _, err := Dial("hub.test:9090", ""); err == nil {
+		t.Fatal("dial without a client name: got no error")
+	}
+}
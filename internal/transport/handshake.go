@@ -0,0 +1,113 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+)
+
+// ALPNProto is the ALPN protocol identifier of the raw TLS transport.
+const ALPNProto = "hub-tunnel/1"
+
+var (
+	handshakeMagic = []byte("HUB\x01")
+
+	// Maximum size of the handshake header block and ack message.
+	maxFrameSize = uint32(64 << 10)
+)
+
+// WriteHandshake writes the handshake frame of the raw TLS transport,
+// carrying the registration headers (X-Hub-Meta-*) of an agent.
+//
+// The frame is made of a magic number, the big-endian uint32 length
+// of the header block and the header block in MIME format.
+func WriteHandshake(w io.Writer, header http.Header) error {
+	var block bytes.Buffer
+	if err := header.Write(&block); err != nil {
+		return err
+	}
+	block.WriteString("\r\n")
+	if err := writeFrame(w, handshakeMagic, block.Bytes()); err != nil {
+		return fmt.Errorf("handshake: %v", err)
+	}
+	return nil
+}
+
+// ReadHandshake reads a handshake frame and returns the headers it carries.
+func ReadHandshake(r io.Reader) (http.Header, error) {
+	magic := make([]byte, len(handshakeMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("handshake: %v", err)
+	}
+	if !bytes.Equal(magic, handshakeMagic) {
+		return nil, fmt.Errorf("handshake: invalid magic number")
+	}
+	block, err := readFrame(r)
+	if err != nil {
+		return nil, fmt.Errorf("handshake: %v", err)
+	}
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+	header, err := tr.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("handshake: %v", err)
+	}
+	return http.Header(header), nil
+}
+
+// WriteAck replies to a handshake. A nil error accepts the registration.
+func WriteAck(w io.Writer, err error) error {
+	var message []byte
+	if err != nil {
+		message = []byte(err.Error())
+		if len(message) == 0 {
+			message = []byte("registration refused")
+		}
+	}
+	return writeFrame(w, nil, message)
+}
+
+// ReadAck reads the reply to a handshake and returns
+// the reason of the refusal if any.
+func ReadAck(r io.Reader) error {
+	message, err := readFrame(r)
+	if err != nil {
+		return fmt.Errorf("handshake ack: %v", err)
+	}
+	if len(message) > 0 {
+		return fmt.Errorf("registration refused: %s", message)
+	}
+	return nil
+}
+
+func writeFrame(w io.Writer, prefix, payload []byte) error {
+	if uint32(len(payload)) > maxFrameSize {
+		return fmt.Errorf("frame too large")
+	}
+	buf := make([]byte, 0, len(prefix)+4+len(payload))
+	buf = append(buf, prefix...)
+	buf = append(buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(prefix):], uint32(len(payload)))
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("frame too large")
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
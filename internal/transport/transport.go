@@ -0,0 +1,297 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devodev/grpc-demo/internal/certs"
+	ws "github.com/devodev/grpc-demo/internal/websocket"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http2"
+)
+
+// Supported transport schemes.
+const (
+	SchemeWS    = "ws"
+	SchemeWSS   = "wss"
+	SchemeTLS   = "tls"
+	SchemeHTTP2 = "h2"
+)
+
+var defaultHandshakeTimeout = 10 * time.Second
+
+// Dialer dials a hub and returns the connection carrying
+// the yamux session of an agent.
+//
+// The transport is selected by the scheme of the uri:
+//
+//	ws://, wss://  websocket upgrade on the hub HTTP server
+//	tls://         raw TLS with a framed handshake on the hub tunnel listener
+//	h2://          HTTP/2 CONNECT on the hub tunnel listener
+type Dialer struct {
+	TLSConfig        *tls.Config
+	HandshakeTimeout time.Duration
+
+	// Certificates, if set, provides the client certificate and the CA
+	// bundle used to verify the hub, reloaded when their files change.
+	Certificates *certs.Reloader
+
+	// Websocket message size limits, see websocket.WithReadLimit
+	// and websocket.WithMaxFrameSize.
+	WebsocketReadLimit    int64
+	WebsocketMaxFrameSize int
+
+	// WebsocketWriteCoalescing enables websocket.WithWriteCoalescing.
+	WebsocketWriteCoalescing bool
+
+	// WebsocketCompression negotiates the permessage-deflate extension,
+	// see websocket.WithCompression and websocket.WithCompressionThreshold.
+	WebsocketCompression          bool
+	WebsocketCompressionLevel     int
+	WebsocketCompressionThreshold int
+}
+
+// Dial dials uri and sends header as the registration attributes.
+func (d *Dialer) Dial(uri string, header http.Header) (io.ReadWriteCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	timeout := d.HandshakeTimeout
+	if timeout == 0 {
+		timeout = defaultHandshakeTimeout
+	}
+	switch u.Scheme {
+	case SchemeWS, SchemeWSS:
+		return d.dialWebsocket(u, header, timeout)
+	case SchemeTLS:
+		return d.dialTLS(u, header, timeout)
+	case SchemeHTTP2:
+		return d.dialHTTP2(u, header, timeout)
+	}
+	return nil, fmt.Errorf("unsupported transport scheme: %q", u.Scheme)
+}
+
+func (d *Dialer) tlsConfig(u *url.URL, protos ...string) *tls.Config {
+	var c *tls.Config
+	if d.TLSConfig != nil {
+		c = d.TLSConfig.Clone()
+	} else {
+		c = &tls.Config{}
+	}
+	if c.ServerName == "" {
+		c.ServerName = u.Hostname()
+	}
+	if len(protos) > 0 {
+		c.NextProtos = protos
+	}
+	if d.Certificates != nil {
+		c = d.Certificates.ClientConfig(c, c.ServerName)
+	}
+	return c
+}
+
+func (d *Dialer) dialWebsocket(u *url.URL, header http.Header, timeout time.Duration) (io.ReadWriteCloser, error) {
+	dialer := &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  timeout,
+		EnableCompression: d.WebsocketCompression,
+	}
+	if u.Scheme == SchemeWSS {
+		dialer.TLSClientConfig = d.tlsConfig(u)
+	}
+	wsHeader := header.Clone()
+	ws.SetReadLimitHeader(wsHeader, d.WebsocketReadLimit)
+	wsConn, resp, err := dialer.Dial(u.String(), wsHeader)
+	if err != nil {
+		return nil, err
+	}
+	opts := []ws.RWCOption{
+		ws.WithReadLimit(d.WebsocketReadLimit),
+		ws.WithMaxFrameSize(d.WebsocketMaxFrameSize),
+		ws.WithPeerReadLimit(resp.Header),
+	}
+	if d.WebsocketWriteCoalescing {
+		opts = append(opts, ws.WithWriteCoalescing())
+	}
+	if d.WebsocketCompression {
+		opts = append(opts,
+			ws.WithCompression(d.WebsocketCompressionLevel),
+			ws.WithCompressionThreshold(d.WebsocketCompressionThreshold),
+		)
+	}
+	wsRwc, err := ws.ReadWriteCloser(wsConn, opts...)
+	if err != nil {
+		wsConn.Close()
+		return nil, err
+	}
+	return wsRwc, nil
+}
+
+func (d *Dialer) dialTLS(u *url.URL, header http.Header, timeout time.Duration) (io.ReadWriteCloser, error) {
+	netDialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(netDialer, "tcp", u.Host, d.tlsConfig(u, ALPNProto))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := WriteHandshake(conn, header); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ReadAck(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *Dialer) dialHTTP2(u *url.URL, header http.Header, timeout time.Duration) (io.ReadWriteCloser, error) {
+	t := &http2.Transport{
+		TLSClientConfig: d.tlsConfig(u, http2.NextProtoTLS),
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, addr, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if p := conn.ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+				conn.Close()
+				return nil, fmt.Errorf("unexpected ALPN protocol %q; want %q", p, http2.NextProtoTLS)
+			}
+			return conn, nil
+		},
+	}
+	pr, pw := io.Pipe()
+	// The context of the request bounds the handshake and, once the
+	// stream is established, lives until it is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Scheme: "https", Host: u.Host},
+		Host:   u.Host,
+		Header: header,
+		Body:   pr,
+	}).WithContext(ctx)
+
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := t.RoundTrip(req)
+	if !timer.Stop() {
+		// The timeout canceled the stream, even if a response raced it.
+		if err == nil {
+			resp.Body.Close()
+		}
+		err = fmt.Errorf("handshake timeout")
+	}
+	if err != nil {
+		cancel()
+		pw.Close()
+		t.CloseIdleConnections()
+		return nil, fmt.Errorf("h2 connect: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		pw.Close()
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxFrameSize)))
+		return nil, fmt.Errorf("registration refused: %v: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return &h2ClientConn{r: resp.Body, w: pw, t: t, cancel: cancel}, nil
+}
+
+// h2ClientConn is the agent end of an HTTP/2 CONNECT stream.
+type h2ClientConn struct {
+	r      io.ReadCloser
+	w      *io.PipeWriter
+	t      *http2.Transport
+	cancel context.CancelFunc
+}
+
+func (c *h2ClientConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *h2ClientConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func (c *h2ClientConn) Close() error {
+	c.w.Close()
+	err := c.r.Close()
+	c.cancel()
+	c.t.CloseIdleConnections()
+	return err
+}
+
+// H2ServerConn is the hub end of an HTTP/2 CONNECT stream.
+//
+// The handler serving the stream must call Wait before returning,
+// since the ResponseWriter cannot be used once the handler returns.
+type H2ServerConn struct {
+	r io.ReadCloser
+	w http.ResponseWriter
+	f http.Flusher
+
+	mu       sync.Mutex
+	finished bool
+
+	once sync.Once
+	done chan struct{}
+}
+
+// NewH2ServerConn accepts the CONNECT request r and returns the stream
+// as a ReadWriteCloser.
+func NewH2ServerConn(w http.ResponseWriter, r *http.Request) (*H2ServerConn, error) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodConnect {
+		return nil, fmt.Errorf("not an HTTP/2 CONNECT request")
+	}
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return &H2ServerConn{r: r.Body, w: w, f: f, done: make(chan struct{})}, nil
+}
+
+// Read implements io.Reader.
+func (c *H2ServerConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Write implements io.Writer.
+func (c *H2ServerConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	c.f.Flush()
+	return n, nil
+}
+
+// Close ends the stream.
+func (c *H2ServerConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.r.Close()
+	})
+	return nil
+}
+
+// Wait blocks until the stream is closed and no write is in progress.
+func (c *H2ServerConn) Wait() {
+	<-c.done
+	c.mu.Lock()
+	c.finished = true
+	c.mu.Unlock()
+}
@@ -1,9 +1,14 @@
 package hub
 
 import (
+	"compress/flate"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,7 +25,17 @@ var (
 	pingPeriod = (pongWait * 9) / 10
 
 	// Maximum message size allowed from peer.
-	maxMessageSize = int64(512)
+	defaultReadLimit = int64(1 << 20)
+
+	// Maximum size of the messages written to the peer.
+	// Larger buffers are split into multiple messages.
+	defaultMaxFrameSize = 32 << 10
+
+	// Messages smaller than this size are not compressed.
+	defaultCompressionThreshold = 256
+
+	// Number of writes queued when write coalescing is enabled.
+	writeQueueSize = 64
 
 	// CloseNormalClosure is the default websocket close message.
 	// The substring "closed" must be present for the
@@ -28,6 +43,21 @@ var (
 	closeNormalClosureMessage = "closed"
 )
 
+// ErrClosed is returned by writes on a closed RWC.
+var ErrClosed = errors.New("websocket: use of closed connection")
+
+// ReadLimitHeader is the HTTP header used by both ends of a websocket
+// to advertise their read limit during the handshake.
+const ReadLimitHeader = "X-Hub-Ws-Read-Limit"
+
+// SetReadLimitHeader advertises the provided read limit in h.
+func SetReadLimitHeader(h http.Header, n int64) {
+	if n <= 0 {
+		n = defaultReadLimit
+	}
+	h.Set(ReadLimitHeader, strconv.FormatInt(n, 10))
+}
+
 // PongHandlerFunc .
 type PongHandlerFunc func(appData string) error
 
@@ -50,6 +80,93 @@ func WithPongHandler(f PongHandlerFunc) RWCOption {
 	}
 }
 
+// WithReadLimit sets the maximum size of a message read from the peer.
+// A zero value keeps the default.
+func WithReadLimit(n int64) RWCOption {
+	return func(c *RWC) error {
+		if n < 0 {
+			return fmt.Errorf("invalid read limit: %v", n)
+		}
+		if n > 0 {
+			c.readLimit = n
+		}
+		return nil
+	}
+}
+
+// WithMaxFrameSize sets the maximum size of a message written to the peer.
+// Writes larger than n are split into multiple messages.
+// A zero value keeps the default.
+func WithMaxFrameSize(n int) RWCOption {
+	return func(c *RWC) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max frame size: %v", n)
+		}
+		if n > 0 {
+			c.maxFrameSize = n
+		}
+		return nil
+	}
+}
+
+// WithPeerReadLimit bounds the maximum frame size to the read limit
+// advertised by the peer in h, if any.
+func WithPeerReadLimit(h http.Header) RWCOption {
+	return func(c *RWC) error {
+		v := h.Get(ReadLimitHeader)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid peer read limit: %q", v)
+		}
+		c.peerReadLimit = n
+		return nil
+	}
+}
+
+// WithWriteCoalescing enables write coalescing.
+//
+// Writes are copied to a queue and return immediately, consecutive
+// queued writes being sent as a single message of at most the maximum
+// frame size. A write error is then returned by the next Write or Close.
+func WithWriteCoalescing() RWCOption {
+	return func(c *RWC) error {
+		c.coalesce = true
+		return nil
+	}
+}
+
+// WithCompression enables the compression of the messages written to the
+// peer using the provided flate compression level, provided that
+// permessage-deflate was negotiated during the handshake.
+func WithCompression(level int) RWCOption {
+	return func(c *RWC) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level: %v", level)
+		}
+		c.compress = true
+		c.compressionLevel = level
+		return nil
+	}
+}
+
+// WithCompressionThreshold sets the minimum size of a message for it
+// to be compressed. Smaller messages are not worth the deflate overhead.
+// A zero value keeps the default.
+func WithCompressionThreshold(n int) RWCOption {
+	return func(c *RWC) error {
+		if n < 0 {
+			return fmt.Errorf("invalid compression threshold: %v", n)
+		}
+		if n > 0 {
+			c.compressionThreshold = n
+		}
+		return nil
+	}
+}
+
 // WithMessageType sets the message type to use in Read/Write.
 func WithMessageType(mt int) RWCOption {
 	if mt != websocket.BinaryMessage && mt != websocket.TextMessage {
@@ -63,51 +180,162 @@ func WithMessageType(mt int) RWCOption {
 	}
 }
 
-// RWC .
+// RWC is a websocket ReadWriteCloser.
+//
+// All frames (data, ping and close) are written by a single goroutine
+// consuming a write queue, since a websocket connection supports only
+// one concurrent writer. Once a write fails, the RWC stops writing and
+// every subsequent Write and Close returns the same error.
 type RWC struct {
 	r  io.Reader
 	mt int
 	c  *websocket.Conn
 
+	readLimit     int64
+	maxFrameSize  int
+	peerReadLimit int64
+	coalesce      bool
+
+	compress             bool
+	compressionLevel     int
+	compressionThreshold int
+
 	pingEnabled     bool
-	pingTicker      *time.Ticker
 	pongHandlerFunc PongHandlerFunc
+	peerClosed      chan struct{}
+
+	writeCh   chan *writeRequest
+	writeDone chan struct{}
+
+	// closing is set before the close message is queued, so that no
+	// write is queued after it, reported successful but never sent.
+	queueMu sync.RWMutex
+	closing bool
+
+	mu  sync.Mutex
+	err error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type writeRequest struct {
+	mt   int
+	data []byte
+
+	// result receives the outcome of the write.
+	// It is nil for coalesced writes.
+	result chan error
 }
 
 // ReadWriteCloser returns a websocket ReadWriteCloser enforcing the provided
 // message type on write/read.
 func ReadWriteCloser(conn *websocket.Conn, options ...RWCOption) (*RWC, error) {
 	rwc := &RWC{
-		mt:              websocket.BinaryMessage,
-		c:               conn,
+		mt:           websocket.BinaryMessage,
+		c:            conn,
+		readLimit:    defaultReadLimit,
+		maxFrameSize: defaultMaxFrameSize,
+
+		compressionThreshold: defaultCompressionThreshold,
+
 		pingEnabled:     true,
 		pongHandlerFunc: func(string) error { conn.SetReadDeadline(time.Now().Add(pongWait)); return nil },
+		peerClosed:      make(chan struct{}),
+		writeDone:       make(chan struct{}),
 	}
 	for _, opt := range options {
 		if err := opt(rwc); err != nil {
 			return nil, err
 		}
 	}
-	conn.SetPongHandler(rwc.pongHandlerFunc)
-	if rwc.pingEnabled {
-		rwc.enablePing()
+	if rwc.peerReadLimit > 0 && int64(rwc.maxFrameSize) > rwc.peerReadLimit {
+		rwc.maxFrameSize = int(rwc.peerReadLimit)
 	}
+	if rwc.coalesce {
+		rwc.writeCh = make(chan *writeRequest, writeQueueSize)
+	} else {
+		rwc.writeCh = make(chan *writeRequest)
+	}
+	if rwc.compress {
+		if err := conn.SetCompressionLevel(rwc.compressionLevel); err != nil {
+			return nil, err
+		}
+	}
+	conn.SetReadLimit(rwc.readLimit)
+	conn.SetPongHandler(rwc.pongHandlerFunc)
+
+	var once sync.Once
+	conn.SetCloseHandler(func(code int, text string) error {
+		once.Do(func() { close(rwc.peerClosed) })
+		return nil
+	})
+	go rwc.writePump()
 	return rwc, nil
 }
 
-// Write .
+// Write writes p as one or more messages of at most
+// the maximum frame size.
 func (c *RWC) Write(p []byte) (int, error) {
-	c.c.SetWriteDeadline(time.Now().Add(writeWait))
-	err := c.c.WriteMessage(c.mt, p)
-	if err != nil {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := c.writeError(); err != nil {
+		return 0, err
+	}
+	req := &writeRequest{mt: c.mt}
+	if c.coalesce {
+		req.data = append([]byte(nil), p...)
+	} else {
+		req.data = p
+		req.result = make(chan error, 1)
+	}
+	if err := c.queue(req); err != nil {
 		return 0, err
 	}
-	return len(p), nil
+	if req.result == nil {
+		return len(p), nil
+	}
+	select {
+	case err := <-req.result:
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	case <-c.writeDone:
+		select {
+		case err := <-req.result:
+			if err == nil {
+				return len(p), nil
+			}
+		default:
+		}
+		return 0, c.writeError()
+	}
+}
+
+// queue queues req unless the RWC is closing or its writes stopped.
+func (c *RWC) queue(req *writeRequest) error {
+	c.queueMu.RLock()
+	defer c.queueMu.RUnlock()
+	if c.closing {
+		return ErrClosed
+	}
+	select {
+	case c.writeCh <- req:
+		return nil
+	case <-c.writeDone:
+		return c.writeError()
+	}
+}
+
+// MaxFrameSize returns the maximum size of the messages written to the peer.
+func (c *RWC) MaxFrameSize() int {
+	return c.maxFrameSize
 }
 
 // Read .
 func (c *RWC) Read(p []byte) (int, error) {
-	c.c.SetReadLimit(maxMessageSize)
 	c.c.SetReadDeadline(time.Now().Add(pongWait))
 	for {
 		if c.r == nil {
@@ -144,48 +372,150 @@ func (c *RWC) Close() error {
 
 // CloseWithMessage closes the underlying websocket connection
 // and uses the provided string as close message.
+//
+// The close message is written after the writes already queued.
+// It returns the write error that stopped the RWC, if any.
 func (c *RWC) CloseWithMessage(m string) error {
-	c.c.SetWriteDeadline(time.Now().Add(writeWait))
-	c.c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, m))
-	return c.c.Close()
+	c.closeOnce.Do(func() {
+		c.queueMu.Lock()
+		c.closing = true
+		c.queueMu.Unlock()
+
+		req := &writeRequest{
+			mt:   websocket.CloseMessage,
+			data: websocket.FormatCloseMessage(websocket.CloseNormalClosure, m),
+		}
+		// Bound the wait in case the pump is stuck on a slow peer.
+		timer := time.NewTimer(writeWait)
+		defer timer.Stop()
+		select {
+		case c.writeCh <- req:
+			select {
+			case <-c.writeDone:
+			case <-timer.C:
+			}
+		case <-c.writeDone:
+		case <-timer.C:
+		}
+		c.c.Close()
+		<-c.writeDone
+		if err := c.writeError(); err != ErrClosed {
+			c.closeErr = err
+		}
+	})
+	return c.closeErr
 }
 
 func (c *RWC) setPongHandler(f PongHandlerFunc) {
 	c.pongHandlerFunc = f
 }
 
-// EnablePing .
-func (c *RWC) enablePing() {
-	if c.pingTicker != nil {
-		return
+func (c *RWC) writeError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *RWC) setWriteError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
 	}
-	done := make(chan struct{})
-	c.c.SetCloseHandler(func(code int, text string) error {
-		close(done)
-		return nil
-	})
-	go c.ping(done)
 }
 
-// ping is a long running goroutine that sends ping messages.
-func (c *RWC) ping(done chan struct{}) {
-	if done == nil {
-		return
+// writePump is a long running goroutine that writes the queued frames
+// and sends ping messages. It returns after writing a close message
+// or on the first write error.
+func (c *RWC) writePump() {
+	defer close(c.writeDone)
+
+	var pingCh <-chan time.Time
+	if c.pingEnabled {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		pingCh = ticker.C
 	}
-	c.pingTicker = time.NewTicker(pingPeriod)
-	defer c.pingTicker.Stop()
+	peerClosed := c.peerClosed
+
+	var next *writeRequest
 	for {
-		select {
-		case <-done:
+		req := next
+		next = nil
+		if req == nil {
+			select {
+			case req = <-c.writeCh:
+			case <-pingCh:
+				req = &writeRequest{mt: websocket.PingMessage}
+			case <-peerClosed:
+				// Stop pinging once the peer started the closing handshake.
+				pingCh, peerClosed = nil, nil
+				continue
+			}
+		}
+
+		var err error
+		switch req.mt {
+		case websocket.CloseMessage:
+			c.c.SetWriteDeadline(time.Now().Add(writeWait))
+			c.c.WriteMessage(websocket.CloseMessage, req.data)
+			c.setWriteError(ErrClosed)
 			return
-		case <-c.pingTicker.C:
+		case websocket.PingMessage:
 			c.c.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.c.WriteMessage(websocket.PingMessage, nil); err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure) {
-					log.Printf("error sending ping: %v", err)
-				}
-				return
+			err = c.c.WriteMessage(websocket.PingMessage, nil)
+			if err != nil && websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure) {
+				log.Printf("error sending ping: %v", err)
+			}
+		default:
+			data := req.data
+			if req.result == nil {
+				data, next = c.coalesceWrites(data)
 			}
+			err = c.writeFrames(req.mt, data)
+		}
+		if req.result != nil {
+			req.result <- err
+		}
+		if err != nil {
+			c.setWriteError(err)
+			return
+		}
+	}
+}
+
+// coalesceWrites appends the queued coalesced writes to data without
+// exceeding the maximum frame size. It returns the first queued request
+// that could not be appended, if any.
+func (c *RWC) coalesceWrites(data []byte) ([]byte, *writeRequest) {
+	for len(data) < c.maxFrameSize {
+		select {
+		case req := <-c.writeCh:
+			if req.result != nil || req.mt != c.mt || len(data)+len(req.data) > c.maxFrameSize {
+				return data, req
+			}
+			data = append(data, req.data...)
+		default:
+			return data, nil
+		}
+	}
+	return data, nil
+}
+
+// writeFrames writes p as one or more messages of at most
+// the maximum frame size.
+func (c *RWC) writeFrames(mt int, p []byte) error {
+	for len(p) > 0 {
+		n := len(p)
+		if n > c.maxFrameSize {
+			n = c.maxFrameSize
+		}
+		c.c.EnableWriteCompression(c.compress && n >= c.compressionThreshold)
+		c.c.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.c.WriteMessage(mt, p[:n]); err != nil {
+			return err
 		}
+		p = p[n:]
 	}
+	return nil
 }
@@ -0,0 +1,377 @@
+package hub
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testConns holds both ends of a websocket served by an httptest server.
+type testConns struct {
+	srv    *httptest.Server
+	client *websocket.Conn
+	server *websocket.Conn
+
+	// req and resp are the handshake request received by the server
+	// and its response received by the client.
+	req  *http.Request
+	resp *http.Response
+}
+
+// dialTestConns dials an httptest server upgrading the connection,
+// header being sent with the handshake and respHeader with its response.
+func dialTestConns(tb testing.TB, header, respHeader http.Header) *testConns {
+	tb.Helper()
+	serverCh := make(chan *websocket.Conn, 1)
+	reqCh := make(chan *http.Request, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, respHeader)
+		if err != nil {
+			tb.Error(err)
+			return
+		}
+		reqCh <- r
+		serverCh <- conn
+	}))
+	client, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		srv.Close()
+		tb.Fatal(err)
+	}
+	return &testConns{srv: srv, client: client, server: <-serverCh, req: <-reqCh, resp: resp}
+}
+
+func (c *testConns) Close() {
+	c.client.Close()
+	c.server.Close()
+	c.srv.Close()
+}
+
+// newTestRWCs returns the client end of a websocket as an RWC along with
+// a channel receiving the number of bytes read by the server end until
+// the connection is closed.
+func newTestRWCs(tb testing.TB, opts ...RWCOption) (*testConns, *RWC, <-chan int64) {
+	tb.Helper()
+	conns := dialTestConns(tb, nil, nil)
+	client, err := ReadWriteCloser(conns.client, opts...)
+	if err != nil {
+		conns.Close()
+		tb.Fatal(err)
+	}
+	// The client end is not read: answering its pings would leave unread
+	// data making its close reset the connection, losing the last writes.
+	conns.server.SetPingHandler(func(string) error { return nil })
+	server, err := ReadWriteCloser(conns.server, WithPingDisabled())
+	if err != nil {
+		conns.Close()
+		tb.Fatal(err)
+	}
+	read := make(chan int64, 1)
+	go func() {
+		n, _ := io.Copy(ioutil.Discard, server)
+		read <- n
+	}()
+	return conns, client, read
+}
+
+func TestConcurrentWritePingAndClose(t *testing.T) {
+	defer func(d time.Duration) { pingPeriod = d }(pingPeriod)
+	pingPeriod = time.Millisecond
+
+	for _, tt := range []struct {
+		name string
+		opts []RWCOption
+	}{
+		{"direct", nil},
+		{"coalesced", []RWCOption{WithWriteCoalescing()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conns, c, read := newTestRWCs(t, tt.opts...)
+			defer conns.Close()
+
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				written int64
+			)
+			start := make(chan struct{})
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p := make([]byte, 512)
+					<-start
+					for {
+						n, err := c.Write(p)
+						if err != nil {
+							if err != ErrClosed {
+								t.Errorf("write: got %v, want %v", err, ErrClosed)
+							}
+							if n != 0 {
+								t.Errorf("failed write: got %v bytes written", n)
+							}
+							return
+						}
+						mu.Lock()
+						written += int64(n)
+						mu.Unlock()
+					}
+				}()
+			}
+			close(start)
+			time.Sleep(20 * time.Millisecond)
+			if err := c.CloseWithMessage("done"); err != nil {
+				t.Errorf("close: %v", err)
+			}
+			wg.Wait()
+
+			// Every write that succeeded was queued before the close
+			// message and must have reached the peer.
+			select {
+			case n := <-read:
+				if n != written {
+					t.Errorf("bytes read by the peer: got %v, want %v", n, written)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("peer did not see the connection close")
+			}
+		})
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	conns, c, _ := newTestRWCs(t)
+	defer conns.Close()
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if n, err := c.Write([]byte("data")); n != 0 || err != ErrClosed {
+			t.Fatalf("write after close: got (%v, %v), want (0, %v)", n, err, ErrClosed)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+func TestWriteErrorIsSticky(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []RWCOption
+	}{
+		{"direct", nil},
+		{"coalesced", []RWCOption{WithWriteCoalescing()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conns, c, _ := newTestRWCs(t, tt.opts...)
+			defer conns.Close()
+
+			// Fail the writes of the pump.
+			conns.client.UnderlyingConn().Close()
+
+			// Coalesced writes only report the error on a later call.
+			var err error
+			deadline := time.Now().Add(5 * time.Second)
+			for err == nil && time.Now().Before(deadline) {
+				_, err = c.Write([]byte("data"))
+			}
+			if err == nil || err == ErrClosed {
+				t.Fatalf("write on a broken connection: got %v, want the write error", err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, got := c.Write([]byte("data")); got != err {
+					t.Fatalf("later write: got %v, want %v", got, err)
+				}
+			}
+			if got := c.Close(); got != err {
+				t.Fatalf("close: got %v, want %v", got, err)
+			}
+		})
+	}
+}
+
+// readMessageSizes reads the messages of conn until it is closed
+// and returns their sizes.
+func readMessageSizes(conn *websocket.Conn) []int {
+	var sizes []int
+	for {
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			return sizes
+		}
+		sizes = append(sizes, len(p))
+	}
+}
+
+func TestWriteSplitsFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []RWCOption
+		writes []int
+		want   []int
+	}{
+		{"smaller", nil, []int{1000}, []int{1000}},
+		{"exact", nil, []int{1024}, []int{1024}},
+		{"larger", nil, []int{2500}, []int{1024, 1024, 452}},
+		{"coalesced", []RWCOption{WithWriteCoalescing()}, []int{2500}, []int{1024, 1024, 452}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conns := dialTestConns(t, nil, nil)
+			defer conns.Close()
+			opts := append([]RWCOption{WithPingDisabled(), WithMaxFrameSize(1024)}, tt.opts...)
+			c, err := ReadWriteCloser(conns.client, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.MaxFrameSize(); got != 1024 {
+				t.Fatalf("max frame size: got %v, want %v", got, 1024)
+			}
+			sizes := make(chan []int, 1)
+			go func() { sizes <- readMessageSizes(conns.server) }()
+
+			for _, n := range tt.writes {
+				if _, err := c.Write(make([]byte, n)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := <-sizes; fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("message sizes: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoalescedWritesFitFrames(t *testing.T) {
+	conns := dialTestConns(t, nil, nil)
+	defer conns.Close()
+	c, err := ReadWriteCloser(conns.client, WithPingDisabled(), WithMaxFrameSize(1024), WithWriteCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(chan []int, 1)
+	go func() { sizes <- readMessageSizes(conns.server) }()
+
+	const writes, size = 100, 300
+	for i := 0; i < writes; i++ {
+		if _, err := c.Write(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, n := range <-sizes {
+		if n > 1024 || n%size != 0 {
+			t.Fatalf("message of %v bytes: want whole writes of at most %v bytes", n, 1024)
+		}
+		total += n
+	}
+	if total != writes*size {
+		t.Fatalf("bytes read: got %v, want %v", total, writes*size)
+	}
+}
+
+func TestReadLimitNegotiation(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxFrameSize int
+		peerLimit    int64 // advertised by the peer unless zero
+		want         int
+	}{
+		{"no header", 0, 0, defaultMaxFrameSize},
+		{"no header with max frame size", 64 << 10, 0, 64 << 10},
+		{"smaller peer limit", 0, 4 << 10, 4 << 10},
+		{"larger peer limit", 0, 1 << 20, defaultMaxFrameSize},
+		{"peer limit below max frame size", 64 << 10, 48 << 10, 48 << 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The client advertises its limit to the server in the
+			// handshake request, the server in its response.
+			var header http.Header
+			if tt.peerLimit > 0 {
+				header = make(http.Header)
+				SetReadLimitHeader(header, tt.peerLimit)
+			}
+			conns := dialTestConns(t, header, header)
+			defer conns.Close()
+
+			for side, conn := range map[string]struct {
+				c *websocket.Conn
+				h http.Header
+			}{
+				"client": {conns.client, conns.resp.Header},
+				"server": {conns.server, conns.req.Header},
+			} {
+				c, err := ReadWriteCloser(conn.c, WithPingDisabled(), WithMaxFrameSize(tt.maxFrameSize), WithPeerReadLimit(conn.h))
+				if err != nil {
+					t.Fatalf("%s: %v", side, err)
+				}
+				defer c.Close()
+				if got := c.MaxFrameSize(); got != tt.want {
+					t.Errorf("%s: max frame size: got %v, want %v", side, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadLimitHeader(t *testing.T) {
+	h := make(http.Header)
+	SetReadLimitHeader(h, 0)
+	if got, want := h.Get(ReadLimitHeader), fmt.Sprint(defaultReadLimit); got != want {
+		t.Fatalf("default read limit header: got %q, want %q", got, want)
+	}
+	for _, v := range []string{"0", "-1", "1k"} {
+		h.Set(ReadLimitHeader, v)
+		if err := WithPeerReadLimit(h)(&RWC{}); err == nil {
+			t.Errorf("peer read limit %q: got no error", v)
+		}
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		opts []RWCOption
+	}{
+		{"direct", []RWCOption{WithPingDisabled()}},
+		{"coalesced", []RWCOption{WithPingDisabled(), WithWriteCoalescing()}},
+	} {
+		for _, size := range []int{64, 4 << 10} {
+			b.Run(fmt.Sprintf("%s/%dB", bb.name, size), func(b *testing.B) {
+				conns, c, read := newTestRWCs(b, bb.opts...)
+				defer conns.Close()
+
+				p := make([]byte, size)
+				b.SetBytes(int64(size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := c.Write(p); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := c.Close(); err != nil {
+					b.Fatal(err)
+				}
+				<-read
+			})
+		}
+	}
+}